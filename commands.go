@@ -0,0 +1,334 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/url"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/pluckware/tyvt/internal/client"
+	"github.com/pluckware/tyvt/internal/limiter"
+	"github.com/pluckware/tyvt/internal/rotator"
+	"github.com/pluckware/tyvt/pkg/config"
+	"github.com/pluckware/tyvt/pkg/store"
+	"github.com/pluckware/tyvt/pkg/validation"
+)
+
+// runQuery implements the query subcommand, which lists the URLs that the
+// latest scan of each domain found and no earlier scan had reported.
+func runQuery(args []string) error {
+	fs := flag.NewFlagSet("query", flag.ExitOnError)
+	dbPath := fs.String("db", "", "SQLite database written with -db (required)")
+	domain := fs.String("domain", "", "Only list URLs of this domain")
+	asJSON := fs.Bool("json", false, "Print one JSON object per URL instead of plain URLs")
+	fs.Parse(args)
+
+	if *dbPath == "" {
+		fmt.Fprintf(os.Stderr, "Usage: %s query -db tyvt.db [-domain example.com] [-json]\n", os.Args[0])
+		fs.PrintDefaults()
+		os.Exit(1)
+	}
+
+	if _, err := os.Stat(*dbPath); err != nil {
+		return fmt.Errorf("cannot open database: %w", err)
+	}
+
+	historyStore, err := store.Open(*dbPath)
+	if err != nil {
+		return err
+	}
+	defer historyStore.Close()
+
+	urls, err := historyStore.NewURLs(*domain)
+	if err != nil {
+		return err
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	for _, u := range urls {
+		if *asJSON {
+			if err := encoder.Encode(u); err != nil {
+				return err
+			}
+			continue
+		}
+		fmt.Println(u.URL)
+	}
+
+	return nil
+}
+
+// keyCheck is one row of the keys check report.
+type keyCheck struct {
+	Key              string `json:"key"`
+	Valid            bool   `json:"valid"`
+	Tier             string `json:"tier,omitempty"`
+	DailyRemaining   int    `json:"daily_remaining"`
+	MonthlyRemaining int    `json:"monthly_remaining"`
+	Error            string `json:"error,omitempty"`
+}
+
+// runKeys implements the keys subcommand. Its only action, check, verifies
+// every key in a keys file with one authenticated API call per key and
+// prints its validity, tier and remaining allowance.
+func runKeys(args []string) error {
+	if len(args) == 0 || args[0] != "check" {
+		fmt.Fprintf(os.Stderr, "Usage: %s keys check -k keys.txt [-json]\n", os.Args[0])
+		os.Exit(1)
+	}
+
+	fs := flag.NewFlagSet("keys check", flag.ExitOnError)
+	keysFile := fs.String("k", "", "Path to API keys file (required)")
+	proxyURL := fs.String("p", "", "Proxy URL (optional)")
+	insecureTLS := fs.Bool("insecure-tls", false, "Skip TLS certificate verification")
+	asJSON := fs.Bool("json", false, "Print one JSON object per key instead of a table")
+	fs.Parse(args[1:])
+
+	if *keysFile == "" {
+		fmt.Fprintf(os.Stderr, "Usage: %s keys check -k keys.txt [-json]\n", os.Args[0])
+		fs.PrintDefaults()
+		os.Exit(1)
+	}
+
+	cfg, err := config.LoadKeys(*keysFile)
+	if err != nil {
+		return err
+	}
+	keys := cfg.APIKeys
+
+	var proxy *url.URL
+	if *proxyURL != "" {
+		if proxy, err = validation.ValidateProxyURL(*proxyURL); err != nil {
+			return fmt.Errorf("invalid proxy URL: %w", err)
+		}
+	}
+
+	keyRotator := rotator.NewKeyRotator(keys, time.Hour)
+	defer keyRotator.Stop()
+	vtClient := client.NewVirusTotalClient(keyRotator, limiter.New(0), proxy, *insecureTLS, client.APIv3)
+
+	checks := checkKeys(context.Background(), vtClient, keys)
+
+	failed := 0
+	for _, check := range checks {
+		if !check.Valid {
+			failed++
+		}
+	}
+
+	if *asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		for _, check := range checks {
+			if err := encoder.Encode(check); err != nil {
+				return err
+			}
+		}
+	} else {
+		printKeyChecks(checks)
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d keys failed the check", failed, len(checks))
+	}
+
+	return nil
+}
+
+// checkKeys checks each key in turn. Keys that do not even have the format
+// of an API key are reported without calling the API.
+func checkKeys(ctx context.Context, vtClient *client.VirusTotalClient, keys []string) []keyCheck {
+	checks := make([]keyCheck, 0, len(keys))
+
+	for _, key := range keys {
+		check := keyCheck{Key: "..." + validation.MaskAPIKey(key)}
+
+		if err := validation.ValidateAPIKey(key); err != nil {
+			check.Error = err.Error()
+			checks = append(checks, check)
+			continue
+		}
+
+		status, err := vtClient.CheckKey(ctx, key)
+		switch {
+		case err != nil:
+			check.Error = err.Error()
+		case !status.Valid:
+			check.Error = fmt.Sprintf("rejected by the API (status %d)", status.Status)
+		default:
+			check.Valid = true
+			check.Tier = status.Tier
+			check.DailyRemaining = status.DailyRemaining()
+			check.MonthlyRemaining = status.MonthlyRemaining()
+		}
+
+		checks = append(checks, check)
+	}
+
+	return checks
+}
+
+func printKeyChecks(checks []keyCheck) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "KEY\tVALID\tTIER\tDAILY LEFT\tMONTHLY LEFT\tERROR")
+
+	for _, check := range checks {
+		if !check.Valid {
+			fmt.Fprintf(w, "%s\tno\t-\t-\t-\t%s\n", check.Key, check.Error)
+			continue
+		}
+		fmt.Fprintf(w, "%s\tyes\t%s\t%d\t%d\t\n", check.Key, check.Tier, check.DailyRemaining, check.MonthlyRemaining)
+	}
+
+	w.Flush()
+}
+
+// keyQuotaStatus is one key's row of the quota report.
+type keyQuotaStatus struct {
+	Key          string    `json:"key"`
+	DailyUsed    int       `json:"daily_used"`
+	DailyLimit   int       `json:"daily_limit"`
+	DailyReset   time.Time `json:"daily_reset"`
+	MonthlyUsed  int       `json:"monthly_used"`
+	MonthlyLimit int       `json:"monthly_limit"`
+	MonthlyReset time.Time `json:"monthly_reset"`
+}
+
+// left returns how many more requests the key can make today, which the
+// monthly limit may cap below the daily one.
+func (s keyQuotaStatus) left() int {
+	return max(min(s.DailyLimit-s.DailyUsed, s.MonthlyLimit-s.MonthlyUsed), 0)
+}
+
+// quotaReport is the JSON output of the quota subcommand.
+type quotaReport struct {
+	Ledger            string           `json:"ledger"`
+	Keys              []keyQuotaStatus `json:"keys"`
+	RequestsPerDomain int              `json:"requests_per_domain"`
+	DomainsLeftToday  int              `json:"domains_left_today"`
+}
+
+// runQuota implements the quota subcommand, which shows the daily and
+// monthly usage of every key as recorded in the quota ledger, when each
+// resets, and how many domains can still be scanned today.
+func runQuota(args []string) error {
+	fs := flag.NewFlagSet("quota", flag.ExitOnError)
+	keysFile := fs.String("k", "", "Path to API keys file (required)")
+	quotaFile := fs.String("quotas", "", "JSON file defining quota profiles (optional)")
+	ledgerPath := fs.String("ledger", limiter.DefaultLedgerPath(), "Quota ledger written by scans")
+	apiVersion := fs.String("api", "v2", "API backend the projection assumes (v2 or v3)")
+	extract := fs.String("extract", "undetected_urls", "Report parts the projection assumes, as for scans")
+	asJSON := fs.Bool("json", false, "Print a JSON document instead of a table")
+	fs.Parse(args)
+
+	if *keysFile == "" || *ledgerPath == "" {
+		fmt.Fprintf(os.Stderr, "Usage: %s quota -k keys.txt [-ledger quota.json] [-json]\n", os.Args[0])
+		fs.PrintDefaults()
+		os.Exit(1)
+	}
+
+	cfg, err := config.LoadKeys(*keysFile)
+	if err != nil {
+		return err
+	}
+
+	var quotas *config.QuotaFile
+	if *quotaFile != "" {
+		if quotas, err = config.LoadQuotaFile(*quotaFile); err != nil {
+			return err
+		}
+	}
+	if err := cfg.ApplyQuotas(quotas); err != nil {
+		return err
+	}
+
+	version, err := client.ParseAPIVersion(*apiVersion)
+	if err != nil {
+		return err
+	}
+	extracts, err := client.ParseExtracts(*extract)
+	if err != nil {
+		return err
+	}
+
+	ledger, err := limiter.OpenLedger(*ledgerPath)
+	if err != nil {
+		return err
+	}
+
+	rateLimiter := limiter.NewWithLimits(quotaLimits(cfg.DefaultQuota))
+	for _, key := range cfg.APIKeys {
+		rateLimiter.SetKeyLimits(key, quotaLimits(cfg.QuotaFor(key)))
+	}
+	if err := rateLimiter.SetLedger(ledger); err != nil {
+		return err
+	}
+
+	report := quotaReport{
+		Ledger:            ledger.Path(),
+		RequestsPerDomain: client.RequestsPerDomain(version, extracts),
+	}
+
+	for _, key := range cfg.APIKeys {
+		daily, monthly := rateLimiter.GetQuotaStatus(key)
+		dailyReset, monthlyReset := rateLimiter.Resets(key)
+		limits := rateLimiter.Limits(key)
+
+		status := keyQuotaStatus{
+			Key:          "..." + validation.MaskAPIKey(key),
+			DailyUsed:    daily,
+			DailyLimit:   limits.Daily,
+			DailyReset:   dailyReset,
+			MonthlyUsed:  monthly,
+			MonthlyLimit: limits.Monthly,
+			MonthlyReset: monthlyReset,
+		}
+		report.Keys = append(report.Keys, status)
+		report.DomainsLeftToday += status.left() / report.RequestsPerDomain
+	}
+
+	if *asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(report)
+	}
+
+	printQuotaReport(report, version)
+	return nil
+}
+
+func printQuotaReport(report quotaReport, version client.APIVersion) {
+	fmt.Printf("Quota ledger: %s\n\n", report.Ledger)
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "KEY\tDAILY\tRESETS IN\tMONTHLY\tRESETS IN")
+	for _, status := range report.Keys {
+		fmt.Fprintf(w, "%s\t%d/%d\t%s\t%d/%d\t%s\n", status.Key,
+			status.DailyUsed, status.DailyLimit, formatUntil(status.DailyReset),
+			status.MonthlyUsed, status.MonthlyLimit, formatUntil(status.MonthlyReset))
+	}
+	w.Flush()
+
+	unit := "requests"
+	if report.RequestsPerDomain == 1 {
+		unit = "request"
+	}
+	fmt.Printf("\nDomains left today: about %d (%s, %d %s per domain)\n",
+		report.DomainsLeftToday, version, report.RequestsPerDomain, unit)
+}
+
+// formatUntil formats the time left until t in days and hours, or hours
+// and minutes below a day.
+func formatUntil(t time.Time) string {
+	d := time.Until(t).Round(time.Minute)
+	if d <= 0 {
+		return "now"
+	}
+	if d >= 24*time.Hour {
+		return fmt.Sprintf("%dd%dh", d/(24*time.Hour), (d%(24*time.Hour))/time.Hour)
+	}
+	return fmt.Sprintf("%dh%02dm", d/time.Hour, (d%time.Hour)/time.Minute)
+}
@@ -0,0 +1,119 @@
+package client
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Extract names a part of the domain report that can be harvested and
+// written to the output.
+type Extract string
+
+const (
+	ExtractUndetectedURLs Extract = "undetected_urls"
+	ExtractDetectedURLs   Extract = "detected_urls"
+	ExtractSubdomains     Extract = "subdomains"
+	ExtractSiblings       Extract = "domain_siblings"
+	ExtractResolutions    Extract = "resolutions"
+	ExtractSamples        Extract = "samples"
+)
+
+// AllExtracts lists every Extract in output order.
+var AllExtracts = []Extract{
+	ExtractUndetectedURLs,
+	ExtractDetectedURLs,
+	ExtractSubdomains,
+	ExtractSiblings,
+	ExtractResolutions,
+	ExtractSamples,
+}
+
+// ExtractSet is the set of report parts selected for output.
+type ExtractSet map[Extract]bool
+
+// DefaultExtracts selects only undetected URLs, the tool's original output.
+func DefaultExtracts() ExtractSet {
+	return ExtractSet{ExtractUndetectedURLs: true}
+}
+
+// Has reports whether e is selected.
+func (s ExtractSet) Has(e Extract) bool {
+	return s[e]
+}
+
+// Filter returns a copy of result without the report parts that are not
+// selected. A nil result stays nil.
+func (s ExtractSet) Filter(result *DomainResult) *DomainResult {
+	if result == nil {
+		return nil
+	}
+
+	r := *result
+
+	if !s.Has(ExtractUndetectedURLs) {
+		r.UndetectedURLs = nil
+	}
+	if !s.Has(ExtractDetectedURLs) {
+		r.DetectedURLs = nil
+	}
+	if !s.Has(ExtractSubdomains) {
+		r.Subdomains = nil
+	}
+	if !s.Has(ExtractSiblings) {
+		r.DomainSiblings = nil
+	}
+	if !s.Has(ExtractResolutions) {
+		r.Resolutions = nil
+	}
+	if !s.Has(ExtractSamples) {
+		r.DetectedDownloadedSamples = nil
+		r.UndetectedDownloadedSamples = nil
+		r.DetectedCommunicatingSamples = nil
+		r.UndetectedCommunicatingSamples = nil
+	}
+
+	return &r
+}
+
+// ParseExtracts parses a comma separated list of Extract names. "all"
+// selects everything and "siblings" is accepted for domain_siblings.
+func ParseExtracts(list string) (ExtractSet, error) {
+	set := make(ExtractSet)
+
+	for _, name := range strings.Split(list, ",") {
+		name = strings.TrimSpace(strings.ToLower(name))
+		if name == "" {
+			continue
+		}
+
+		if name == "all" {
+			for _, e := range AllExtracts {
+				set[e] = true
+			}
+			continue
+		}
+
+		if name == "siblings" {
+			name = string(ExtractSiblings)
+		}
+
+		found := false
+		for _, e := range AllExtracts {
+			if string(e) == name {
+				set[e] = true
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return nil, fmt.Errorf("unknown extract '%s'", name)
+		}
+	}
+
+	if len(set) == 0 {
+		return nil, fmt.Errorf("no extracts selected")
+	}
+
+	return set, nil
+}
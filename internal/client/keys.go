@@ -0,0 +1,90 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+// publicDailyAllowance is the daily request allowance of a free public API
+// key. Keys allowed more are reported as premium.
+const publicDailyAllowance = 500
+
+// KeyStatus is the result of checking an API key against the v3 quota
+// endpoint. The allowance fields are only set for valid keys.
+type KeyStatus struct {
+	Valid          bool   `json:"valid"`
+	Status         int    `json:"status"`
+	Tier           string `json:"tier,omitempty"`
+	DailyUsed      int    `json:"daily_used"`
+	DailyAllowed   int    `json:"daily_allowed"`
+	MonthlyUsed    int    `json:"monthly_used"`
+	MonthlyAllowed int    `json:"monthly_allowed"`
+}
+
+// DailyRemaining returns how many requests the key has left today.
+func (s *KeyStatus) DailyRemaining() int {
+	return max(s.DailyAllowed-s.DailyUsed, 0)
+}
+
+// MonthlyRemaining returns how many requests the key has left this month.
+func (s *KeyStatus) MonthlyRemaining() int {
+	return max(s.MonthlyAllowed-s.MonthlyUsed, 0)
+}
+
+// v3QuotaResponse is the response of the v3 overall_quotas endpoint. Each
+// quota reports the user's own allowance and usage.
+type v3QuotaResponse struct {
+	Data struct {
+		Daily   v3Quota `json:"api_requests_daily"`
+		Monthly v3Quota `json:"api_requests_monthly"`
+	} `json:"data"`
+}
+
+type v3Quota struct {
+	User struct {
+		Allowed int `json:"allowed"`
+		Used    int `json:"used"`
+	} `json:"user"`
+}
+
+// CheckKey verifies apiKey with one authenticated request to the v3
+// overall_quotas endpoint, which reports the key's allowance without
+// spending it. The request bypasses the rate limiter. A key the API rejects
+// yields a KeyStatus with Valid unset rather than an error.
+func (c *VirusTotalClient) CheckKey(ctx context.Context, apiKey string) (*KeyStatus, error) {
+	quotaURL := fmt.Sprintf("%s/users/%s/overall_quotas", c.v3URL, url.PathEscape(apiKey))
+
+	status, _, body, err := c.do(ctx, apiKey, quotaURL, true)
+	if err != nil {
+		return nil, err
+	}
+
+	keyStatus := &KeyStatus{Status: status}
+	switch {
+	case status == http.StatusUnauthorized || status == http.StatusForbidden || status == http.StatusNotFound:
+		return keyStatus, nil
+	case status != http.StatusOK:
+		return nil, v3Error(status, body)
+	}
+
+	var quotas v3QuotaResponse
+	if err := json.Unmarshal(body, &quotas); err != nil {
+		return nil, fmt.Errorf("failed to parse quota response: %w", err)
+	}
+
+	keyStatus.Valid = true
+	keyStatus.DailyUsed = quotas.Data.Daily.User.Used
+	keyStatus.DailyAllowed = quotas.Data.Daily.User.Allowed
+	keyStatus.MonthlyUsed = quotas.Data.Monthly.User.Used
+	keyStatus.MonthlyAllowed = quotas.Data.Monthly.User.Allowed
+
+	keyStatus.Tier = "public"
+	if keyStatus.DailyAllowed > publicDailyAllowance {
+		keyStatus.Tier = "premium"
+	}
+
+	return keyStatus, nil
+}
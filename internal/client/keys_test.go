@@ -0,0 +1,69 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"testing"
+)
+
+func TestCheckKey(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v3/users/"+testAPIKey+"/overall_quotas" {
+			t.Errorf("Unexpected path %s", r.URL.Path)
+		}
+		if r.Header.Get("x-apikey") != testAPIKey {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"error": {"code": "WrongCredentialsError", "message": "wrong key"}}`))
+			return
+		}
+		w.Write([]byte(`{"data": {
+			"api_requests_daily": {"user": {"allowed": 500, "used": 120}},
+			"api_requests_monthly": {"user": {"allowed": 15500, "used": 15500}}
+		}}`))
+	})
+
+	c := newTestClient(t, handler, APIv2)
+	status, err := c.CheckKey(context.Background(), testAPIKey)
+	if err != nil {
+		t.Fatalf("CheckKey failed: %v", err)
+	}
+
+	if !status.Valid || status.Tier != "public" {
+		t.Errorf("Expected valid public key, got %+v", status)
+	}
+	if status.DailyRemaining() != 380 || status.MonthlyRemaining() != 0 {
+		t.Errorf("Expected 380 daily and 0 monthly requests left, got %d, %d", status.DailyRemaining(), status.MonthlyRemaining())
+	}
+
+	// Checks bypass the rate limiter, so they do not count towards the quota
+	if daily, _ := c.rateLimiter.GetQuotaStatus(testAPIKey); daily != 0 {
+		t.Errorf("Expected check not to count against the quota, got %d requests", daily)
+	}
+}
+
+func TestCheckKey_Rejected(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(`{"error": {"code": "WrongCredentialsError", "message": "wrong key"}}`))
+	})
+
+	c := newTestClient(t, handler, APIv3)
+	status, err := c.CheckKey(context.Background(), testAPIKey)
+	if err != nil {
+		t.Fatalf("Expected rejection to be reported in the status, got %v", err)
+	}
+	if status.Valid || status.Status != http.StatusUnauthorized {
+		t.Errorf("Expected invalid key with status 401, got %+v", status)
+	}
+}
+
+func TestCheckKey_ServerError(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})
+
+	c := newTestClient(t, handler, APIv3)
+	if _, err := c.CheckKey(context.Background(), testAPIKey); err == nil {
+		t.Error("Expected error when the check itself fails")
+	}
+}
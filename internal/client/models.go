@@ -0,0 +1,69 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// FieldError records a field of an API response that could not be parsed.
+// Malformed fields are reported rather than silently dropped so that changes
+// in the upstream schema show up in the results.
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"error"`
+}
+
+func (e FieldError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Field, e.Message)
+}
+
+// fieldDecoder decodes the fields of a response one at a time and collects
+// a FieldError for each one that fails instead of aborting the whole report.
+type fieldDecoder struct {
+	errors []FieldError
+}
+
+func (d *fieldDecoder) fail(field string, err error) {
+	d.errors = append(d.errors, FieldError{Field: field, Message: err.Error()})
+}
+
+// decode unmarshals a single field. Absent and null fields are left at their
+// zero value.
+func (d *fieldDecoder) decode(field string, raw json.RawMessage, v interface{}) {
+	if isAbsent(raw) {
+		return
+	}
+	if err := json.Unmarshal(raw, v); err != nil {
+		d.fail(field, err)
+	}
+}
+
+// decodeList unmarshals a JSON array element by element so that one malformed
+// entry does not discard the rest of the list.
+func decodeList[T any](d *fieldDecoder, field string, raw json.RawMessage) []T {
+	if isAbsent(raw) {
+		return nil
+	}
+
+	var items []json.RawMessage
+	if err := json.Unmarshal(raw, &items); err != nil {
+		d.fail(field, err)
+		return nil
+	}
+
+	list := make([]T, 0, len(items))
+	for i, item := range items {
+		var v T
+		if err := json.Unmarshal(item, &v); err != nil {
+			d.fail(fmt.Sprintf("%s[%d]", field, i), err)
+			continue
+		}
+		list = append(list, v)
+	}
+
+	return list
+}
+
+func isAbsent(raw json.RawMessage) bool {
+	return len(raw) == 0 || string(raw) == "null"
+}
@@ -0,0 +1,100 @@
+package client
+
+import (
+	"context"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// RetryPolicy controls how requests are retried after a rate limit, a
+// server error or a failed connection. Delays grow exponentially from
+// BaseDelay with jitter, up to MaxDelay, unless the response says how long
+// to wait with Retry-After.
+type RetryPolicy struct {
+	MaxAttempts int
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+}
+
+// DefaultRetryPolicy tries each request up to four times over about a
+// minute, which rides out the per-minute limit of a public key.
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 4,
+	BaseDelay:   5 * time.Second,
+	MaxDelay:    2 * time.Minute,
+}
+
+// rateLimited reports whether status is VirusTotal's signal that the key
+// is making requests too fast: 204 on v2 and 429 on v3.
+func rateLimited(status int) bool {
+	return status == http.StatusNoContent || status == http.StatusTooManyRequests
+}
+
+// retryable reports whether a request that ended with status and err may
+// succeed when repeated. Rate limits, server errors and failed connections
+// are retried; other statuses, such as a rejected key or a bad request,
+// would only fail again.
+func retryable(status int, err error) bool {
+	if err != nil {
+		return true
+	}
+
+	switch status {
+	case http.StatusNoContent, http.StatusTooManyRequests, http.StatusInternalServerError,
+		http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+
+	return false
+}
+
+// delay returns how long to wait before the next attempt, after attempt
+// attempts. A Retry-After header in seconds or as an HTTP date is honored;
+// it reports false when that is longer than MaxDelay, as retrying is then
+// not worth the wait.
+func (p RetryPolicy) delay(attempt int, header http.Header) (time.Duration, bool) {
+	if retryAfter, ok := parseRetryAfter(header.Get("Retry-After")); ok {
+		return retryAfter, retryAfter <= p.MaxDelay
+	}
+
+	backoff := p.BaseDelay << (attempt - 1)
+	if backoff <= 0 || backoff > p.MaxDelay {
+		backoff = p.MaxDelay
+	}
+
+	// Equal jitter: at least half the backoff, so that workers hitting the
+	// same limit do not retry in lockstep
+	half := backoff / 2
+	return half + time.Duration(rand.Int64N(int64(half)+1)), true
+}
+
+func parseRetryAfter(value string) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+
+	if at, err := http.ParseTime(value); err == nil {
+		return max(time.Until(at), 0), true
+	}
+
+	return 0, false
+}
+
+// sleep waits for d or until ctx is cancelled.
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
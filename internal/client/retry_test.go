@@ -0,0 +1,195 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/pluckware/tyvt/internal/limiter"
+	"github.com/pluckware/tyvt/internal/rotator"
+	"github.com/pluckware/tyvt/pkg/logger"
+)
+
+var testRetryPolicy = RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: time.Second}
+
+func TestQueryDomain_RetriesRateLimit(t *testing.T) {
+	var requests int32
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&requests, 1) < 3 {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		w.Write([]byte(`{"response_code": 1, "undetected_urls": []}`))
+	})
+
+	c := newTestClient(t, handler, APIv2)
+	c.SetRetryPolicy(testRetryPolicy)
+
+	result, err := c.QueryDomain(context.Background(), "example.com")
+	if err != nil {
+		t.Fatalf("Expected success after retries, got %v", err)
+	}
+	if result.ResponseCode != 1 || requests != 3 {
+		t.Errorf("Expected a result after 3 requests, got %d requests", requests)
+	}
+
+	health := c.KeyHealth()[0]
+	if health.State != rotator.KeyActive || health.Requests != 3 {
+		t.Errorf("Expected key to be active again after 3 requests, got %+v", health)
+	}
+}
+
+func TestQueryDomain_RateLimitThrottlesKey(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "30")
+		w.WriteHeader(http.StatusTooManyRequests)
+	})
+
+	c := newTestClient(t, handler, APIv3)
+	c.SetRetryPolicy(RetryPolicy{MaxAttempts: 2, BaseDelay: time.Millisecond, MaxDelay: 10 * time.Second})
+
+	_, err := c.QueryDomain(context.Background(), "example.com")
+
+	var statusErr *StatusError
+	if !errors.As(err, &statusErr) || statusErr.Status != http.StatusTooManyRequests {
+		t.Fatalf("Expected 429 StatusError, got %v", err)
+	}
+
+	// Retry-After exceeds MaxDelay, so the request gives up at once and
+	// the key is held back for MaxDelay instead of the requested time
+	if wait := time.Until(c.rateLimiter.NextAvailable(testAPIKey)); wait < 9*time.Second || wait > 10*time.Second {
+		t.Errorf("Expected key to be throttled for MaxDelay, got %v", wait)
+	}
+}
+
+func TestQueryDomain_LastAttemptRateLimitThrottlesKey(t *testing.T) {
+	var requests int32
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	c := newTestClient(t, handler, APIv2)
+	c.SetRetryPolicy(RetryPolicy{MaxAttempts: 1, BaseDelay: 20 * time.Second, MaxDelay: time.Minute})
+
+	if _, err := c.QueryDomain(context.Background(), "example.com"); err == nil {
+		t.Fatal("Expected error for a rate limited request")
+	}
+	if requests != 1 {
+		t.Errorf("Expected a single request, got %d", requests)
+	}
+
+	// Without retries the rate limit must still slow the key down, by at
+	// least half the base delay with equal jitter
+	if wait := time.Until(c.rateLimiter.NextAvailable(testAPIKey)); wait < 9*time.Second {
+		t.Errorf("Expected key to be throttled after its only attempt, got %v", wait)
+	}
+}
+
+func TestQueryDomain_DoesNotRetryAuthFailure(t *testing.T) {
+	var requests int32
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.WriteHeader(http.StatusForbidden)
+	})
+
+	c := newTestClient(t, handler, APIv2)
+	c.SetRetryPolicy(testRetryPolicy)
+
+	if _, err := c.QueryDomain(context.Background(), "example.com"); err == nil {
+		t.Fatal("Expected error for 403 response")
+	}
+	if requests != 1 {
+		t.Errorf("Expected a single request, got %d", requests)
+	}
+}
+
+func TestQueryDomain_GivesUpAfterMaxAttempts(t *testing.T) {
+	var requests int32
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})
+
+	c := newTestClient(t, handler, APIv2)
+	c.SetRetryPolicy(testRetryPolicy)
+
+	if _, err := c.QueryDomain(context.Background(), "example.com"); err == nil {
+		t.Fatal("Expected error after the last attempt")
+	}
+	if requests != int32(testRetryPolicy.MaxAttempts) {
+		t.Errorf("Expected %d requests, got %d", testRetryPolicy.MaxAttempts, requests)
+	}
+}
+
+func TestRetryPolicy_Delay(t *testing.T) {
+	policy := RetryPolicy{MaxAttempts: 5, BaseDelay: time.Second, MaxDelay: time.Minute}
+
+	for attempt := 1; attempt <= 8; attempt++ {
+		backoff := min(time.Second<<(attempt-1), time.Minute)
+		delay, ok := policy.delay(attempt, http.Header{})
+		if !ok || delay < backoff/2 || delay > backoff {
+			t.Errorf("Attempt %d: expected delay in [%v, %v], got %v", attempt, backoff/2, backoff, delay)
+		}
+	}
+
+	header := http.Header{}
+	header.Set("Retry-After", "7")
+	if delay, ok := policy.delay(1, header); !ok || delay != 7*time.Second {
+		t.Errorf("Expected Retry-After of 7s to be honored, got %v", delay)
+	}
+
+	header.Set("Retry-After", time.Now().Add(2*time.Hour).UTC().Format(http.TimeFormat))
+	if _, ok := policy.delay(1, header); ok {
+		t.Error("Expected Retry-After beyond MaxDelay not to be retried")
+	}
+}
+
+func TestQueryDomain_LogsAttempts(t *testing.T) {
+	var requests int32
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&requests, 1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`{"response_code": 1}`))
+	})
+
+	var buf bytes.Buffer
+	c := newTestClient(t, handler, APIv2)
+	c.SetRetryPolicy(testRetryPolicy)
+	c.SetLogger(logger.NewWithOptions(logger.Options{Level: logger.LevelDebug, Format: logger.FormatJSON, Output: &buf}))
+
+	if _, err := c.QueryDomain(context.Background(), "example.com"); err != nil {
+		t.Fatalf("Expected success after a retry, got %v", err)
+	}
+
+	var statuses []float64
+	decoder := json.NewDecoder(&buf)
+	for decoder.More() {
+		var record map[string]any
+		if err := decoder.Decode(&record); err != nil {
+			t.Fatalf("Invalid log record: %v", err)
+		}
+		for _, attr := range []string{"domain", "key_id", "attempt", "status", "latency"} {
+			if _, ok := record[attr]; !ok {
+				t.Errorf("Record %q lacks %s attribute", record["msg"], attr)
+			}
+		}
+		if record["key_id"] != limiter.KeyID(testAPIKey) {
+			t.Errorf("Expected key_id %s, got %v", limiter.KeyID(testAPIKey), record["key_id"])
+		}
+		if record["level"] == "DEBUG" {
+			statuses = append(statuses, record["status"].(float64))
+		}
+	}
+
+	if len(statuses) != 2 || statuses[0] != 503 || statuses[1] != 200 {
+		t.Errorf("Expected a request event per attempt with statuses 503 and 200, got %v", statuses)
+	}
+}
@@ -0,0 +1,202 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// v2DomainReport is the envelope of a v2 domain/report response. Every
+// field is kept raw so that it can be decoded independently and a malformed
+// field is reported without losing the others.
+type v2DomainReport struct {
+	ResponseCode                   json.RawMessage `json:"response_code"`
+	VerboseMsg                     string          `json:"verbose_msg"`
+	DetectedURLs                   json.RawMessage `json:"detected_urls"`
+	UndetectedURLs                 json.RawMessage `json:"undetected_urls"`
+	Resolutions                    json.RawMessage `json:"resolutions"`
+	Subdomains                     json.RawMessage `json:"subdomains"`
+	DomainSiblings                 json.RawMessage `json:"domain_siblings"`
+	Whois                          json.RawMessage `json:"whois"`
+	Categories                     json.RawMessage `json:"categories"`
+	DetectedDownloadedSamples      json.RawMessage `json:"detected_downloaded_samples"`
+	UndetectedDownloadedSamples    json.RawMessage `json:"undetected_downloaded_samples"`
+	DetectedCommunicatingSamples   json.RawMessage `json:"detected_communicating_samples"`
+	UndetectedCommunicatingSamples json.RawMessage `json:"undetected_communicating_samples"`
+}
+
+// v2DetectedURL is an entry of detected_urls, which v2 encodes as an object.
+type v2DetectedURL struct {
+	URL       string `json:"url"`
+	Positives int    `json:"positives"`
+	Total     int    `json:"total"`
+	ScanDate  string `json:"scan_date"`
+}
+
+// v2UndetectedURL is an entry of undetected_urls, which v2 encodes as a
+// positional tuple: [url, sha256, positives, total, scan_date].
+type v2UndetectedURL struct {
+	URL       string
+	SHA256    string
+	Positives int
+	Total     int
+	ScanDate  string
+}
+
+func (u *v2UndetectedURL) UnmarshalJSON(data []byte) error {
+	var tuple []json.RawMessage
+	if err := json.Unmarshal(data, &tuple); err != nil {
+		return err
+	}
+
+	if len(tuple) < 5 {
+		return fmt.Errorf("expected 5 elements, got %d", len(tuple))
+	}
+
+	fields := []struct {
+		name string
+		dst  interface{}
+	}{
+		{"url", &u.URL},
+		{"sha256", &u.SHA256},
+		{"positives", &u.Positives},
+		{"total", &u.Total},
+		{"scan_date", &u.ScanDate},
+	}
+
+	for i, f := range fields {
+		if err := json.Unmarshal(tuple[i], f.dst); err != nil {
+			return fmt.Errorf("element %d (%s): %w", i, f.name, err)
+		}
+	}
+
+	return nil
+}
+
+type v2Resolution struct {
+	IPAddress    string `json:"ip_address"`
+	LastResolved string `json:"last_resolved"`
+}
+
+// v2Sample is an entry of the detected/undetected downloaded and
+// communicating sample lists.
+type v2Sample struct {
+	SHA256    string `json:"sha256"`
+	Positives int    `json:"positives"`
+	Total     int    `json:"total"`
+	Date      string `json:"date"`
+}
+
+// v2Domain is the fully decoded form of a v2DomainReport.
+type v2Domain struct {
+	ResponseCode                   int
+	DetectedURLs                   []v2DetectedURL
+	UndetectedURLs                 []v2UndetectedURL
+	Resolutions                    []v2Resolution
+	Subdomains                     []string
+	DomainSiblings                 []string
+	Whois                          string
+	Categories                     []string
+	DetectedDownloadedSamples      []v2Sample
+	UndetectedDownloadedSamples    []v2Sample
+	DetectedCommunicatingSamples   []v2Sample
+	UndetectedCommunicatingSamples []v2Sample
+}
+
+// decodeV2Domain decodes every field of a v2 report, returning the parse
+// failures alongside whatever could be decoded.
+func decodeV2Domain(report *v2DomainReport) (*v2Domain, []FieldError) {
+	d := &fieldDecoder{}
+	domain := &v2Domain{}
+
+	d.decode("response_code", report.ResponseCode, &domain.ResponseCode)
+	d.decode("whois", report.Whois, &domain.Whois)
+	domain.DetectedURLs = decodeList[v2DetectedURL](d, "detected_urls", report.DetectedURLs)
+	domain.UndetectedURLs = decodeList[v2UndetectedURL](d, "undetected_urls", report.UndetectedURLs)
+	domain.Resolutions = decodeList[v2Resolution](d, "resolutions", report.Resolutions)
+	domain.Subdomains = decodeList[string](d, "subdomains", report.Subdomains)
+	domain.DomainSiblings = decodeList[string](d, "domain_siblings", report.DomainSiblings)
+	domain.Categories = decodeList[string](d, "categories", report.Categories)
+	domain.DetectedDownloadedSamples = decodeList[v2Sample](d, "detected_downloaded_samples", report.DetectedDownloadedSamples)
+	domain.UndetectedDownloadedSamples = decodeList[v2Sample](d, "undetected_downloaded_samples", report.UndetectedDownloadedSamples)
+	domain.DetectedCommunicatingSamples = decodeList[v2Sample](d, "detected_communicating_samples", report.DetectedCommunicatingSamples)
+	domain.UndetectedCommunicatingSamples = decodeList[v2Sample](d, "undetected_communicating_samples", report.UndetectedCommunicatingSamples)
+
+	return domain, d.errors
+}
+
+func (c *VirusTotalClient) queryDomainV2(ctx context.Context, apiKey, domain string) (*DomainResult, error) {
+	reqURL := fmt.Sprintf("%s?apikey=%s&domain=%s", c.v2URL, url.QueryEscape(apiKey), url.QueryEscape(domain))
+
+	status, body, err := c.get(ctx, apiKey, domain, reqURL, false)
+	if err != nil {
+		return nil, err
+	}
+
+	if status != http.StatusOK {
+		return nil, &StatusError{Status: status, Body: string(body)}
+	}
+
+	var report v2DomainReport
+	if err := json.Unmarshal(body, &report); err != nil {
+		return nil, fmt.Errorf("failed to parse JSON response: %w", err)
+	}
+
+	decoded, parseErrors := decodeV2Domain(&report)
+
+	result := &DomainResult{
+		Domain:       domain,
+		ResponseCode: decoded.ResponseCode,
+		ParseErrors:  parseErrors,
+		Timestamp:    time.Now(),
+	}
+
+	if c.keepRaw {
+		result.RawResponse = body
+	}
+
+	if result.ResponseCode != 1 {
+		return result, nil
+	}
+
+	result.Categories = decoded.Categories
+	result.Whois = decoded.Whois
+
+	result.Subdomains = decoded.Subdomains
+	result.DomainSiblings = decoded.DomainSiblings
+	result.DetectedDownloadedSamples = convertV2Samples(decoded.DetectedDownloadedSamples)
+	result.UndetectedDownloadedSamples = convertV2Samples(decoded.UndetectedDownloadedSamples)
+	result.DetectedCommunicatingSamples = convertV2Samples(decoded.DetectedCommunicatingSamples)
+	result.UndetectedCommunicatingSamples = convertV2Samples(decoded.UndetectedCommunicatingSamples)
+
+	for _, u := range decoded.UndetectedURLs {
+		result.UndetectedURLs = append(result.UndetectedURLs, UndetectedURL{
+			URL:          u.URL,
+			Positives:    u.Positives,
+			Total:        u.Total,
+			ScanDate:     u.ScanDate,
+			LastModified: time.Now(),
+		})
+	}
+
+	for _, u := range decoded.DetectedURLs {
+		result.DetectedURLs = append(result.DetectedURLs, DetectedURL(u))
+	}
+
+	for _, r := range decoded.Resolutions {
+		result.Resolutions = append(result.Resolutions, Resolution(r))
+	}
+
+	return result, nil
+}
+
+func convertV2Samples(samples []v2Sample) []Sample {
+	var converted []Sample
+	for _, s := range samples {
+		converted = append(converted, Sample(s))
+	}
+	return converted
+}
@@ -0,0 +1,329 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"time"
+)
+
+// v3RelationshipPageLimit is the number of objects requested from each
+// domain relationship. Each page costs one API request, so only the first
+// page is fetched.
+const v3RelationshipPageLimit = 40
+
+// v3AnalysisStats mirrors the last_analysis_stats attribute shared by v3
+// domain and URL objects.
+type v3AnalysisStats struct {
+	Harmless   int `json:"harmless"`
+	Malicious  int `json:"malicious"`
+	Suspicious int `json:"suspicious"`
+	Undetected int `json:"undetected"`
+	Timeout    int `json:"timeout"`
+}
+
+func (s v3AnalysisStats) positives() int {
+	return s.Malicious + s.Suspicious
+}
+
+func (s v3AnalysisStats) total() int {
+	return s.Harmless + s.Malicious + s.Suspicious + s.Undetected + s.Timeout
+}
+
+// v3DomainResponse is the envelope of a v3 domain object. As with v2, the
+// attributes are kept raw and decoded field by field.
+type v3DomainResponse struct {
+	Data struct {
+		ID         string             `json:"id"`
+		Type       string             `json:"type"`
+		Attributes v3DomainAttributes `json:"attributes"`
+	} `json:"data"`
+}
+
+type v3DomainAttributes struct {
+	LastAnalysisStats json.RawMessage `json:"last_analysis_stats"`
+	Categories        json.RawMessage `json:"categories"`
+	Whois             json.RawMessage `json:"whois"`
+	Reputation        json.RawMessage `json:"reputation"`
+	LastDNSRecords    json.RawMessage `json:"last_dns_records"`
+}
+
+type v3DNSRecord struct {
+	Type  string `json:"type"`
+	Value string `json:"value"`
+	TTL   int    `json:"ttl"`
+}
+
+// v3Domain is the fully decoded form of v3DomainAttributes. Categories maps
+// each vendor to the category it assigned.
+type v3Domain struct {
+	LastAnalysisStats v3AnalysisStats
+	Categories        map[string]string
+	Whois             string
+	Reputation        int
+	LastDNSRecords    []v3DNSRecord
+}
+
+func decodeV3Domain(attrs *v3DomainAttributes) (*v3Domain, []FieldError) {
+	d := &fieldDecoder{}
+	domain := &v3Domain{}
+
+	d.decode("last_analysis_stats", attrs.LastAnalysisStats, &domain.LastAnalysisStats)
+	d.decode("categories", attrs.Categories, &domain.Categories)
+	d.decode("whois", attrs.Whois, &domain.Whois)
+	d.decode("reputation", attrs.Reputation, &domain.Reputation)
+	domain.LastDNSRecords = decodeList[v3DNSRecord](d, "last_dns_records", attrs.LastDNSRecords)
+
+	return domain, d.errors
+}
+
+// categoryList flattens the per-vendor category map into a sorted list of
+// distinct categories, the shape v2 reports them in.
+func (d *v3Domain) categoryList() []string {
+	seen := make(map[string]bool)
+	var categories []string
+	for _, category := range d.Categories {
+		if !seen[category] {
+			seen[category] = true
+			categories = append(categories, category)
+		}
+	}
+	sort.Strings(categories)
+	return categories
+}
+
+type v3URLObject struct {
+	ID         string `json:"id"`
+	Type       string `json:"type"`
+	Attributes struct {
+		URL               string          `json:"url"`
+		LastAnalysisDate  int64           `json:"last_analysis_date"`
+		LastAnalysisStats v3AnalysisStats `json:"last_analysis_stats"`
+	} `json:"attributes"`
+}
+
+// v3Object is a relationship entry where only the object ID is needed, such
+// as the subdomains and siblings relationships.
+type v3Object struct {
+	ID   string `json:"id"`
+	Type string `json:"type"`
+}
+
+type v3ResolutionObject struct {
+	ID         string `json:"id"`
+	Attributes struct {
+		IPAddress string `json:"ip_address"`
+		HostName  string `json:"host_name"`
+		Date      int64  `json:"date"`
+	} `json:"attributes"`
+}
+
+// v3FileObject is an entry of the downloaded_files and communicating_files
+// relationships. The object ID is the file's SHA-256.
+type v3FileObject struct {
+	ID         string `json:"id"`
+	Attributes struct {
+		LastAnalysisDate  int64           `json:"last_analysis_date"`
+		LastAnalysisStats v3AnalysisStats `json:"last_analysis_stats"`
+	} `json:"attributes"`
+}
+
+type v3RelationshipResponse struct {
+	Data json.RawMessage `json:"data"`
+}
+
+type v3ErrorResponse struct {
+	Error struct {
+		Code    string `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// v3Error builds an error from a non-200 v3 response, preferring the
+// structured error body when one is present.
+func v3Error(status int, body []byte) error {
+	var errResp v3ErrorResponse
+	if err := json.Unmarshal(body, &errResp); err == nil && errResp.Error.Code != "" {
+		return &StatusError{Status: status, Code: errResp.Error.Code, Message: errResp.Error.Message, Body: string(body)}
+	}
+	return &StatusError{Status: status, Body: string(body)}
+}
+
+// queryDomainV3 fetches the domain object and the relationships needed for
+// the selected extracts from the v3 API, and maps them onto the same
+// DomainResult shape the v2 backend produces. A domain unknown to VirusTotal
+// yields ResponseCode 0, matching v2.
+func (c *VirusTotalClient) queryDomainV3(ctx context.Context, apiKey, domain string) (*DomainResult, error) {
+	domainURL := fmt.Sprintf("%s/domains/%s", c.v3URL, url.PathEscape(domain))
+
+	status, body, err := c.get(ctx, apiKey, domain, domainURL, true)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &DomainResult{
+		Domain:    domain,
+		Timestamp: time.Now(),
+	}
+
+	if status == http.StatusNotFound {
+		return result, nil
+	}
+
+	if status != http.StatusOK {
+		return nil, v3Error(status, body)
+	}
+
+	var domainResp v3DomainResponse
+	if err := json.Unmarshal(body, &domainResp); err != nil {
+		return nil, fmt.Errorf("failed to parse JSON response: %w", err)
+	}
+
+	decoded, parseErrors := decodeV3Domain(&domainResp.Data.Attributes)
+
+	result.ResponseCode = 1
+	result.Categories = decoded.categoryList()
+	result.Whois = decoded.Whois
+	result.ParseErrors = parseErrors
+
+	if c.keepRaw {
+		result.RawResponse = body
+	}
+
+	if c.extracts.Has(ExtractUndetectedURLs) || c.extracts.Has(ExtractDetectedURLs) {
+		urls, err := fetchRelationship[v3URLObject](ctx, c, apiKey, domainURL, "urls", result)
+		if err != nil {
+			return result, err
+		}
+
+		for _, obj := range urls {
+			stats := obj.Attributes.LastAnalysisStats
+			scanDate := v3ScanDate(obj.Attributes.LastAnalysisDate)
+
+			if stats.positives() > 0 {
+				result.DetectedURLs = append(result.DetectedURLs, DetectedURL{
+					URL:       obj.Attributes.URL,
+					Positives: stats.positives(),
+					Total:     stats.total(),
+					ScanDate:  scanDate,
+				})
+				continue
+			}
+
+			result.UndetectedURLs = append(result.UndetectedURLs, UndetectedURL{
+				URL:          obj.Attributes.URL,
+				Positives:    stats.positives(),
+				Total:        stats.total(),
+				ScanDate:     scanDate,
+				LastModified: time.Now(),
+			})
+		}
+	}
+
+	if c.extracts.Has(ExtractSubdomains) {
+		subdomains, err := fetchRelationship[v3Object](ctx, c, apiKey, domainURL, "subdomains", result)
+		if err != nil {
+			return result, err
+		}
+		for _, obj := range subdomains {
+			result.Subdomains = append(result.Subdomains, obj.ID)
+		}
+	}
+
+	if c.extracts.Has(ExtractSiblings) {
+		siblings, err := fetchRelationship[v3Object](ctx, c, apiKey, domainURL, "siblings", result)
+		if err != nil {
+			return result, err
+		}
+		for _, obj := range siblings {
+			result.DomainSiblings = append(result.DomainSiblings, obj.ID)
+		}
+	}
+
+	if c.extracts.Has(ExtractResolutions) {
+		resolutions, err := fetchRelationship[v3ResolutionObject](ctx, c, apiKey, domainURL, "resolutions", result)
+		if err != nil {
+			return result, err
+		}
+		for _, obj := range resolutions {
+			result.Resolutions = append(result.Resolutions, Resolution{
+				IPAddress:    obj.Attributes.IPAddress,
+				LastResolved: v3ScanDate(obj.Attributes.Date),
+			})
+		}
+	}
+
+	if c.extracts.Has(ExtractSamples) {
+		downloaded, err := fetchRelationship[v3FileObject](ctx, c, apiKey, domainURL, "downloaded_files", result)
+		if err != nil {
+			return result, err
+		}
+		result.DetectedDownloadedSamples, result.UndetectedDownloadedSamples = splitV3Samples(downloaded)
+
+		communicating, err := fetchRelationship[v3FileObject](ctx, c, apiKey, domainURL, "communicating_files", result)
+		if err != nil {
+			return result, err
+		}
+		result.DetectedCommunicatingSamples, result.UndetectedCommunicatingSamples = splitV3Samples(communicating)
+	}
+
+	return result, nil
+}
+
+// fetchRelationship fetches the first page of a domain relationship and
+// decodes its objects, appending per-object parse failures to the result.
+func fetchRelationship[T any](ctx context.Context, c *VirusTotalClient, apiKey, domainURL, name string, result *DomainResult) ([]T, error) {
+	relURL := fmt.Sprintf("%s/%s?limit=%d", domainURL, name, v3RelationshipPageLimit)
+
+	status, body, err := c.get(ctx, apiKey, result.Domain, relURL, true)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch %s relationship: %w", name, err)
+	}
+
+	if status != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch %s relationship: %w", name, v3Error(status, body))
+	}
+
+	var relResp v3RelationshipResponse
+	if err := json.Unmarshal(body, &relResp); err != nil {
+		return nil, fmt.Errorf("failed to parse %s relationship: %w", name, err)
+	}
+
+	d := &fieldDecoder{}
+	objects := decodeList[T](d, name, relResp.Data)
+	result.ParseErrors = append(result.ParseErrors, d.errors...)
+
+	return objects, nil
+}
+
+// splitV3Samples separates file objects into detected and undetected
+// samples using their last analysis stats.
+func splitV3Samples(files []v3FileObject) (detected, undetected []Sample) {
+	for _, f := range files {
+		stats := f.Attributes.LastAnalysisStats
+		sample := Sample{
+			SHA256:    f.ID,
+			Positives: stats.positives(),
+			Total:     stats.total(),
+			Date:      v3ScanDate(f.Attributes.LastAnalysisDate),
+		}
+
+		if sample.Positives > 0 {
+			detected = append(detected, sample)
+		} else {
+			undetected = append(undetected, sample)
+		}
+	}
+	return detected, undetected
+}
+
+// v3ScanDate formats a v3 unix timestamp the way v2 reports scan dates.
+func v3ScanDate(timestamp int64) string {
+	if timestamp == 0 {
+		return ""
+	}
+	return time.Unix(timestamp, 0).UTC().Format("2006-01-02 15:04:05")
+}
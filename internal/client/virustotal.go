@@ -4,6 +4,7 @@ import (
 	"context"
 	"crypto/tls"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -12,25 +13,91 @@ import (
 
 	"github.com/pluckware/tyvt/internal/limiter"
 	"github.com/pluckware/tyvt/internal/rotator"
+	"github.com/pluckware/tyvt/pkg/logger"
+	"github.com/pluckware/tyvt/pkg/validation"
 )
 
+// Version is the tyvt release, reported in the User-Agent and output metadata.
+const Version = "1.0.0"
+
+const (
+	VirusTotalAPIURL   = "https://virustotal.com/vtapi/v2/domain/report"
+	VirusTotalAPIv3URL = "https://www.virustotal.com/api/v3"
+	DefaultTimeout     = 30 * time.Second
+)
+
+// APIVersion selects which VirusTotal API backend is used for domain reports.
+type APIVersion string
+
 const (
-	VirusTotalAPIURL = "https://virustotal.com/vtapi/v2/domain/report"
-	DefaultTimeout   = 30 * time.Second
+	APIv2 APIVersion = "v2"
+	APIv3 APIVersion = "v3"
 )
 
+// ParseAPIVersion converts a command line value into an APIVersion.
+func ParseAPIVersion(version string) (APIVersion, error) {
+	switch APIVersion(version) {
+	case APIv2, APIv3:
+		return APIVersion(version), nil
+	default:
+		return "", fmt.Errorf("unsupported API version '%s' (use v2 or v3)", version)
+	}
+}
+
+// RequestsPerDomain returns how many API requests looking up one domain
+// costs. A v2 report is a single request; v3 needs one more for every
+// relationship the selected extracts fetch.
+func RequestsPerDomain(version APIVersion, extracts ExtractSet) int {
+	if version != APIv3 {
+		return 1
+	}
+
+	requests := 1
+	if extracts.Has(ExtractUndetectedURLs) || extracts.Has(ExtractDetectedURLs) {
+		requests++
+	}
+	for _, e := range []Extract{ExtractSubdomains, ExtractSiblings, ExtractResolutions} {
+		if extracts.Has(e) {
+			requests++
+		}
+	}
+	if extracts.Has(ExtractSamples) {
+		requests += 2 // downloaded and communicating files
+	}
+
+	return requests
+}
+
 type VirusTotalClient struct {
 	httpClient  *http.Client
 	keyRotator  *rotator.KeyRotator
 	rateLimiter *limiter.RateLimiter
+	apiVersion  APIVersion
+	v2URL       string
+	v3URL       string
+	keepRaw     bool
+	extracts    ExtractSet
+	retry       RetryPolicy
+	logger      *logger.Logger
 }
 
 type DomainResult struct {
-	Domain         string                 `json:"domain"`
-	ResponseCode   int                    `json:"response_code"`
-	UndetectedURLs []UndetectedURL        `json:"undetected_urls,omitempty"`
-	RawResponse    map[string]interface{} `json:"raw_response,omitempty"`
-	Timestamp      time.Time              `json:"timestamp"`
+	Domain                         string          `json:"domain"`
+	ResponseCode                   int             `json:"response_code"`
+	UndetectedURLs                 []UndetectedURL `json:"undetected_urls,omitempty"`
+	DetectedURLs                   []DetectedURL   `json:"detected_urls,omitempty"`
+	Subdomains                     []string        `json:"subdomains,omitempty"`
+	DomainSiblings                 []string        `json:"domain_siblings,omitempty"`
+	Resolutions                    []Resolution    `json:"resolutions,omitempty"`
+	DetectedDownloadedSamples      []Sample        `json:"detected_downloaded_samples,omitempty"`
+	UndetectedDownloadedSamples    []Sample        `json:"undetected_downloaded_samples,omitempty"`
+	DetectedCommunicatingSamples   []Sample        `json:"detected_communicating_samples,omitempty"`
+	UndetectedCommunicatingSamples []Sample        `json:"undetected_communicating_samples,omitempty"`
+	Categories                     []string        `json:"categories,omitempty"`
+	Whois                          string          `json:"whois,omitempty"`
+	ParseErrors                    []FieldError    `json:"parse_errors,omitempty"`
+	RawResponse                    json.RawMessage `json:"raw_response,omitempty"`
+	Timestamp                      time.Time       `json:"timestamp"`
 }
 
 type UndetectedURL struct {
@@ -41,6 +108,38 @@ type UndetectedURL struct {
 	LastModified time.Time `json:"last_modified"`
 }
 
+// DetectedURL is a URL under the domain that at least one engine flagged.
+type DetectedURL struct {
+	URL       string `json:"url"`
+	Positives int    `json:"positives"`
+	Total     int    `json:"total"`
+	ScanDate  string `json:"scan_date"`
+}
+
+// Resolution is a passive DNS record for the domain.
+type Resolution struct {
+	IPAddress    string `json:"ip_address"`
+	LastResolved string `json:"last_resolved"`
+}
+
+// Sample is a file downloaded from, or communicating with, the domain.
+type Sample struct {
+	SHA256    string `json:"sha256"`
+	Positives int    `json:"positives"`
+	Total     int    `json:"total"`
+	Date      string `json:"date"`
+}
+
+// Samples returns all four sample lists concatenated.
+func (r *DomainResult) Samples() []Sample {
+	var samples []Sample
+	samples = append(samples, r.DetectedDownloadedSamples...)
+	samples = append(samples, r.UndetectedDownloadedSamples...)
+	samples = append(samples, r.DetectedCommunicatingSamples...)
+	samples = append(samples, r.UndetectedCommunicatingSamples...)
+	return samples
+}
+
 // NewVirusTotalClient creates a new VirusTotal API client.
 // proxyURL is optional - pass nil for no proxy.
 // insecureTLS skips certificate verification (use only with trusted proxies that perform TLS inspection).
@@ -52,7 +151,9 @@ type UndetectedURL struct {
 //   - You understand the security implications
 //
 // For production, prefer adding the proxy's CA certificate to your system trust store.
-func NewVirusTotalClient(keyRotator *rotator.KeyRotator, rateLimiter *limiter.RateLimiter, proxyURL *url.URL, insecureTLS bool) *VirusTotalClient {
+//
+// apiVersion selects the backend; an empty value defaults to APIv2.
+func NewVirusTotalClient(keyRotator *rotator.KeyRotator, rateLimiter *limiter.RateLimiter, proxyURL *url.URL, insecureTLS bool, apiVersion APIVersion) *VirusTotalClient {
 	transport := &http.Transport{}
 
 	// Configure proxy if provided
@@ -75,117 +176,204 @@ func NewVirusTotalClient(keyRotator *rotator.KeyRotator, rateLimiter *limiter.Ra
 		},
 		keyRotator:  keyRotator,
 		rateLimiter: rateLimiter,
+		apiVersion:  apiVersion,
+		v2URL:       VirusTotalAPIURL,
+		v3URL:       VirusTotalAPIv3URL,
+		extracts:    DefaultExtracts(),
+		retry:       DefaultRetryPolicy,
+		logger:      logger.Discard(),
 	}
 }
 
-func (c *VirusTotalClient) QueryDomain(ctx context.Context, domain string) (*DomainResult, error) {
-	apiKey := c.keyRotator.CurrentKey()
-	if apiKey == "" {
-		return nil, fmt.Errorf("no API key available")
-	}
-
-	if err := c.rateLimiter.Wait(ctx, apiKey); err != nil {
-		return nil, fmt.Errorf("rate limiter error: %w", err)
-	}
+// SetRetryPolicy sets how failed requests are retried. The default is
+// DefaultRetryPolicy; a MaxAttempts of 1 disables retries.
+func (c *VirusTotalClient) SetRetryPolicy(policy RetryPolicy) {
+	c.retry = policy
+}
 
-	reqURL := fmt.Sprintf("%s?apikey=%s&domain=%s", VirusTotalAPIURL, url.QueryEscape(apiKey), url.QueryEscape(domain))
+// SetLogger logs every request, and every retry, to l. Nothing is logged
+// by default.
+func (c *VirusTotalClient) SetLogger(l *logger.Logger) {
+	c.logger = l
+}
 
-	req, err := http.NewRequestWithContext(ctx, "GET", reqURL, nil)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
+// SetEndpoints points the client at other v2 and v3 API base URLs, such
+// as a mirror or a test server. An empty URL keeps the current one.
+func (c *VirusTotalClient) SetEndpoints(v2URL, v3URL string) {
+	if v2URL != "" {
+		c.v2URL = v2URL
+	}
+	if v3URL != "" {
+		c.v3URL = v3URL
 	}
+}
 
-	req.Header.Set("User-Agent", "tyvt/1.0")
+// SetKeepRawResponse controls whether the unparsed API response body is
+// attached to each DomainResult. It is off by default to keep memory and
+// output size down.
+func (c *VirusTotalClient) SetKeepRawResponse(keep bool) {
+	c.keepRaw = keep
+}
 
-	resp, err := c.httpClient.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("failed to execute request: %w", err)
-	}
-	defer resp.Body.Close()
+// SetExtracts selects which parts of the domain report are harvested. The
+// v2 report always carries every part, but the v3 backend needs one extra
+// relationship request per part, so only the selected ones are fetched.
+func (c *VirusTotalClient) SetExtracts(extracts ExtractSet) {
+	c.extracts = extracts
+}
 
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read response body: %w", err)
-	}
+// Keys returns the API keys available to this client.
+func (c *VirusTotalClient) Keys() []string {
+	return c.keyRotator.Keys()
+}
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("API returned status %d: %s", resp.StatusCode, string(body))
-	}
+// KeyHealth returns the health of every API key, as tracked by the rotator
+// from the responses to this client's requests.
+func (c *VirusTotalClient) KeyHealth() []rotator.KeyHealth {
+	return c.keyRotator.Health()
+}
 
-	var rawResponse map[string]interface{}
-	if err := json.Unmarshal(body, &rawResponse); err != nil {
-		return nil, fmt.Errorf("failed to parse JSON response: %w", err)
-	}
+// APIVersion returns the backend this client queries.
+func (c *VirusTotalClient) APIVersion() APIVersion {
+	return c.apiVersion
+}
 
-	result := &DomainResult{
-		Domain:      domain,
-		RawResponse: rawResponse,
-		Timestamp:   time.Now(),
+// QueryDomain fetches the domain report for a single domain using the
+// configured API backend and the key the rotator selects.
+func (c *VirusTotalClient) QueryDomain(ctx context.Context, domain string) (*DomainResult, error) {
+	apiKey, err := c.keyRotator.SelectKey("")
+	if err != nil {
+		return nil, err
 	}
+	return c.QueryDomainWithKey(ctx, apiKey, domain)
+}
 
-	if responseCode, ok := rawResponse["response_code"].(float64); ok {
-		result.ResponseCode = int(responseCode)
-	}
+// SelectKey returns preferred, or another key when it has no quota left.
+// See rotator.KeyRotator.SelectKey.
+func (c *VirusTotalClient) SelectKey(preferred string) (string, error) {
+	return c.keyRotator.SelectKey(preferred)
+}
 
-	if result.ResponseCode != 1 {
-		return result, nil
+// QueryDomainWithKey is QueryDomain with an explicit API key, for callers
+// that assign keys themselves such as the scanner's per-key workers.
+func (c *VirusTotalClient) QueryDomainWithKey(ctx context.Context, apiKey, domain string) (*DomainResult, error) {
+	if apiKey == "" {
+		return nil, fmt.Errorf("no API key available")
 	}
 
-	if err := c.parseUndetectedURLs(rawResponse, result); err != nil {
-		return result, fmt.Errorf("failed to parse undetected URLs: %w", err)
+	if c.apiVersion == APIv3 {
+		return c.queryDomainV3(ctx, apiKey, domain)
 	}
 
-	return result, nil
+	return c.queryDomainV2(ctx, apiKey, domain)
 }
 
-func (c *VirusTotalClient) parseUndetectedURLs(rawResponse map[string]interface{}, result *DomainResult) error {
-	undetectedInterface, exists := rawResponse["undetected_urls"]
-	if !exists {
-		return nil
-	}
+// StatusError is returned for an API response with an unexpected HTTP
+// status. Code and Message are set when the v3 API explains the error.
+type StatusError struct {
+	Status  int
+	Code    string
+	Message string
+	Body    string
+}
 
-	undetectedArray, ok := undetectedInterface.([]interface{})
-	if !ok {
-		return nil
+func (e *StatusError) Error() string {
+	if e.Code != "" {
+		return fmt.Sprintf("API returned status %d: %s: %s", e.Status, e.Code, e.Message)
 	}
+	return fmt.Sprintf("API returned status %d: %s", e.Status, e.Body)
+}
 
-	for _, item := range undetectedArray {
-		urlData, ok := item.([]interface{})
-		if !ok || len(urlData) < 5 {
-			continue
+// AuthFailure reports whether the API rejected the key itself.
+func (e *StatusError) AuthFailure() bool {
+	return e.Status == http.StatusUnauthorized || e.Status == http.StatusForbidden
+}
+
+// get performs a rate limited GET request with the given API key and returns
+// the response status and body. v3 requests authenticate with the x-apikey
+// header; v2 requests carry the key in the query string. Each status is
+// reported to the key rotator, which tracks the health of each key.
+// Retryable failures are repeated according to the retry policy. When the
+// API rate limits the key, the wait is passed on to the rate limiter, so
+// that every request with that key slows down, not just this one. Every
+// attempt is logged with the domain it is made for.
+func (c *VirusTotalClient) get(ctx context.Context, apiKey, domain, reqURL string, headerAuth bool) (int, []byte, error) {
+	log := c.logger.With("domain", domain, "key_id", limiter.KeyID(apiKey))
+
+	for attempt := 1; ; attempt++ {
+		if err := c.rateLimiter.Wait(ctx, apiKey); err != nil {
+			return 0, nil, fmt.Errorf("rate limiter error: %w", err)
 		}
 
-		url, ok := urlData[0].(string)
-		if !ok {
-			continue
+		start := time.Now()
+		status, header, body, err := c.do(ctx, apiKey, reqURL, headerAuth)
+		latency := time.Since(start)
+		if status != 0 {
+			c.keyRotator.ReportStatus(apiKey, status)
 		}
 
-		// Skip urlData[1] - it's the SHA256 hash (string)
-		positives, ok := urlData[2].(float64)
-		if !ok {
-			continue
+		event := log.With("attempt", attempt, "status", status, "latency", latency)
+		if err != nil {
+			event.Debug("Request failed: %v", err)
+		} else {
+			event.Debug("Request completed with status %d", status)
 		}
 
-		total, ok := urlData[3].(float64)
-		if !ok {
-			continue
+		// A rate limit slows the key down even when this was the last
+		// attempt, so that the next request with it does not hit it again.
+		// A Retry-After beyond MaxDelay holds the key no longer than
+		// MaxDelay, as the scan waits for every worker's key in turn.
+		delay, ok := c.retry.delay(attempt, header)
+		if rateLimited(status) {
+			c.rateLimiter.Throttle(apiKey, min(delay, c.retry.MaxDelay))
 		}
 
-		scanDate, ok := urlData[4].(string)
-		if !ok {
-			continue
+		if attempt >= c.retry.MaxAttempts || !retryable(status, err) || ctx.Err() != nil || !ok {
+			return status, body, err
 		}
+		event.Warn("Retrying request in %s", delay.Round(time.Millisecond))
 
-		undetectedURL := UndetectedURL{
-			URL:          url,
-			Positives:    int(positives),
-			Total:        int(total),
-			ScanDate:     scanDate,
-			LastModified: time.Now(),
+		if !rateLimited(status) {
+			if err := sleep(ctx, delay); err != nil {
+				return 0, nil, err
+			}
 		}
+	}
+}
+
+// do performs a GET request without rate limiting, retries or health
+// tracking.
+func (c *VirusTotalClient) do(ctx context.Context, apiKey, reqURL string, headerAuth bool) (int, http.Header, []byte, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", reqURL, nil)
+	if err != nil {
+		return 0, nil, nil, fmt.Errorf("failed to create request: %w", redactURL(err, apiKey))
+	}
+
+	req.Header.Set("User-Agent", "tyvt/"+Version)
+	if headerAuth {
+		req.Header.Set("x-apikey", apiKey)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return 0, nil, nil, fmt.Errorf("failed to execute request: %w", redactURL(err, apiKey))
+	}
+	defer resp.Body.Close()
 
-		result.UndetectedURLs = append(result.UndetectedURLs, undetectedURL)
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return resp.StatusCode, resp.Header, nil, fmt.Errorf("failed to read response body: %w", err)
 	}
 
-	return nil
-}
\ No newline at end of file
+	return resp.StatusCode, resp.Header, body, nil
+}
+
+// redactURL masks apiKey in the request URL that err carries, if it is a
+// *url.Error, as the v2 API and the key check put the key in the URL.
+func redactURL(err error, apiKey string) error {
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		urlErr.URL = validation.RedactQuery(validation.RedactKey(urlErr.URL, apiKey))
+	}
+	return err
+}
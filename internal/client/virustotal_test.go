@@ -0,0 +1,386 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pluckware/tyvt/internal/limiter"
+	"github.com/pluckware/tyvt/internal/rotator"
+)
+
+const testAPIKey = "c9a9cfea8329cdf114760ed36fc8468dd1a1cb826d4adab9fee96bad9ec74add"
+
+func newTestClient(t *testing.T, handler http.Handler, version APIVersion) *VirusTotalClient {
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	keyRotator := rotator.NewKeyRotator([]string{testAPIKey}, time.Second)
+	c := NewVirusTotalClient(keyRotator, limiter.New(time.Millisecond), nil, false, version)
+	c.v2URL = server.URL + "/vtapi/v2/domain/report"
+	c.v3URL = server.URL + "/api/v3"
+	return c
+}
+
+func TestParseAPIVersion(t *testing.T) {
+	for _, v := range []string{"v2", "v3"} {
+		if _, err := ParseAPIVersion(v); err != nil {
+			t.Errorf("ParseAPIVersion(%q) unexpected error: %v", v, err)
+		}
+	}
+
+	if _, err := ParseAPIVersion("v4"); err == nil {
+		t.Error("Expected error for unsupported API version")
+	}
+}
+
+func TestQueryDomain_V2(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("apikey") != testAPIKey {
+			t.Errorf("Expected apikey query parameter")
+		}
+		if r.URL.Query().Get("domain") != "example.com" {
+			t.Errorf("Expected domain example.com, got %s", r.URL.Query().Get("domain"))
+		}
+		w.Write([]byte(`{
+			"response_code": 1,
+			"undetected_urls": [
+				["http://example.com/a", "abc", 0, 70, "2024-01-02 03:04:05"],
+				["http://example.com/b", "def", 0, 71, "2024-01-03 03:04:05"]
+			]
+		}`))
+	})
+
+	c := newTestClient(t, handler, APIv2)
+	result, err := c.QueryDomain(context.Background(), "example.com")
+	if err != nil {
+		t.Fatalf("QueryDomain failed: %v", err)
+	}
+
+	if result.ResponseCode != 1 {
+		t.Errorf("Expected response code 1, got %d", result.ResponseCode)
+	}
+	if len(result.UndetectedURLs) != 2 {
+		t.Fatalf("Expected 2 undetected URLs, got %d", len(result.UndetectedURLs))
+	}
+	if result.UndetectedURLs[1].Total != 71 {
+		t.Errorf("Expected total 71, got %d", result.UndetectedURLs[1].Total)
+	}
+}
+
+func TestQueryDomain_V3(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v3/domains/example.com", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("x-apikey") != testAPIKey {
+			t.Errorf("Expected x-apikey header")
+		}
+		if r.URL.Query().Get("apikey") != "" {
+			t.Errorf("v3 requests must not carry the key in the query string")
+		}
+		w.Write([]byte(`{"data": {"id": "example.com", "type": "domain", "attributes": {}}}`))
+	})
+	mux.HandleFunc("/api/v3/domains/example.com/urls", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"data": [
+			{"id": "1", "type": "url", "attributes": {
+				"url": "http://example.com/clean",
+				"last_analysis_date": 1704164645,
+				"last_analysis_stats": {"harmless": 60, "undetected": 10, "malicious": 0, "suspicious": 0, "timeout": 0}}},
+			{"id": "2", "type": "url", "attributes": {
+				"url": "http://example.com/bad",
+				"last_analysis_stats": {"harmless": 60, "undetected": 5, "malicious": 5}}}
+		]}`))
+	})
+
+	c := newTestClient(t, mux, APIv3)
+	result, err := c.QueryDomain(context.Background(), "example.com")
+	if err != nil {
+		t.Fatalf("QueryDomain failed: %v", err)
+	}
+
+	if result.ResponseCode != 1 {
+		t.Errorf("Expected response code 1, got %d", result.ResponseCode)
+	}
+	if len(result.UndetectedURLs) != 1 {
+		t.Fatalf("Expected 1 undetected URL, got %d", len(result.UndetectedURLs))
+	}
+
+	u := result.UndetectedURLs[0]
+	if u.URL != "http://example.com/clean" || u.Positives != 0 || u.Total != 70 {
+		t.Errorf("Unexpected undetected URL mapping: %+v", u)
+	}
+	if u.ScanDate != "2024-01-02 03:04:05" {
+		t.Errorf("Expected v2 style scan date, got %q", u.ScanDate)
+	}
+}
+
+func TestQueryDomain_V3NotFound(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"error": {"code": "NotFoundError", "message": "not found"}}`))
+	})
+
+	c := newTestClient(t, handler, APIv3)
+	result, err := c.QueryDomain(context.Background(), "unknown.example")
+	if err != nil {
+		t.Fatalf("Expected no error for unknown domain, got %v", err)
+	}
+	if result.ResponseCode != 0 {
+		t.Errorf("Expected response code 0, got %d", result.ResponseCode)
+	}
+}
+
+func TestQueryDomain_V3Error(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte(`{"error": {"code": "ForbiddenError", "message": "forbidden"}}`))
+	})
+
+	c := newTestClient(t, handler, APIv3)
+	_, err := c.QueryDomain(context.Background(), "example.com")
+
+	var statusErr *StatusError
+	if !errors.As(err, &statusErr) {
+		t.Fatalf("Expected StatusError for 403 response, got %v", err)
+	}
+	if !statusErr.AuthFailure() || statusErr.Code != "ForbiddenError" {
+		t.Errorf("Expected forbidden auth failure, got %+v", statusErr)
+	}
+
+	health := c.KeyHealth()
+	if len(health) != 1 || health[0].State != rotator.KeyForbidden || health[0].Requests != 1 {
+		t.Errorf("Expected the response to mark the key forbidden, got %+v", health)
+	}
+}
+
+func TestQueryDomain_V2ReportsMalformedFields(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{
+			"response_code": 1,
+			"categories": "not-a-list",
+			"undetected_urls": [
+				["http://example.com/a", "abc", 0, 70, "2024-01-02 03:04:05"],
+				["http://example.com/short", "def", 0],
+				["http://example.com/c", "ghi", "zero", 70, "2024-01-02 03:04:05"]
+			]
+		}`))
+	})
+
+	c := newTestClient(t, handler, APIv2)
+	result, err := c.QueryDomain(context.Background(), "example.com")
+	if err != nil {
+		t.Fatalf("QueryDomain failed: %v", err)
+	}
+
+	if len(result.UndetectedURLs) != 1 {
+		t.Errorf("Expected the 1 well-formed URL to be kept, got %d", len(result.UndetectedURLs))
+	}
+
+	fields := make(map[string]bool)
+	for _, fieldErr := range result.ParseErrors {
+		fields[fieldErr.Field] = true
+	}
+
+	for _, want := range []string{"categories", "undetected_urls[1]", "undetected_urls[2]"} {
+		if !fields[want] {
+			t.Errorf("Expected parse error for %s, got %v", want, result.ParseErrors)
+		}
+	}
+}
+
+func TestQueryDomain_RawResponseOptIn(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"response_code": 1}`))
+	})
+
+	c := newTestClient(t, handler, APIv2)
+	result, err := c.QueryDomain(context.Background(), "example.com")
+	if err != nil {
+		t.Fatalf("QueryDomain failed: %v", err)
+	}
+	if result.RawResponse != nil {
+		t.Error("Expected raw response to be omitted by default")
+	}
+
+	c.SetKeepRawResponse(true)
+	result, err = c.QueryDomain(context.Background(), "example.com")
+	if err != nil {
+		t.Fatalf("QueryDomain failed: %v", err)
+	}
+	if string(result.RawResponse) != `{"response_code": 1}` {
+		t.Errorf("Expected raw response to be kept, got %s", result.RawResponse)
+	}
+}
+
+func TestQueryDomain_V2HarvestsReport(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{
+			"response_code": 1,
+			"detected_urls": [{"url": "http://example.com/bad", "positives": 3, "total": 70, "scan_date": "2024-01-02 03:04:05"}],
+			"subdomains": ["www.example.com", "api.example.com"],
+			"domain_siblings": ["example.net"],
+			"resolutions": [{"ip_address": "192.0.2.1", "last_resolved": "2024-01-01 00:00:00"}],
+			"detected_downloaded_samples": [{"sha256": "aa", "positives": 5, "total": 70, "date": "2024-01-01 00:00:00"}],
+			"undetected_communicating_samples": [{"sha256": "bb", "positives": 0, "total": 70, "date": "2024-01-01 00:00:00"}]
+		}`))
+	})
+
+	c := newTestClient(t, handler, APIv2)
+	result, err := c.QueryDomain(context.Background(), "example.com")
+	if err != nil {
+		t.Fatalf("QueryDomain failed: %v", err)
+	}
+
+	if len(result.DetectedURLs) != 1 || result.DetectedURLs[0].Positives != 3 {
+		t.Errorf("Unexpected detected URLs: %+v", result.DetectedURLs)
+	}
+	if len(result.Subdomains) != 2 {
+		t.Errorf("Expected 2 subdomains, got %v", result.Subdomains)
+	}
+	if len(result.DomainSiblings) != 1 {
+		t.Errorf("Expected 1 sibling, got %v", result.DomainSiblings)
+	}
+	if len(result.Resolutions) != 1 || result.Resolutions[0].IPAddress != "192.0.2.1" {
+		t.Errorf("Unexpected resolutions: %+v", result.Resolutions)
+	}
+	if len(result.Samples()) != 2 {
+		t.Errorf("Expected 2 samples, got %+v", result.Samples())
+	}
+}
+
+func TestQueryDomain_V3FetchesSelectedRelationships(t *testing.T) {
+	var requested []string
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v3/domains/example.com", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"data": {"id": "example.com", "type": "domain", "attributes": {}}}`))
+	})
+	mux.HandleFunc("/api/v3/domains/example.com/", func(w http.ResponseWriter, r *http.Request) {
+		requested = append(requested, r.URL.Path)
+		switch r.URL.Path {
+		case "/api/v3/domains/example.com/subdomains":
+			w.Write([]byte(`{"data": [{"id": "www.example.com", "type": "domain"}]}`))
+		case "/api/v3/domains/example.com/resolutions":
+			w.Write([]byte(`{"data": [{"id": "192.0.2.1example.com", "attributes": {"ip_address": "192.0.2.1", "date": 1704164645}}]}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})
+
+	c := newTestClient(t, mux, APIv3)
+	c.SetExtracts(ExtractSet{ExtractSubdomains: true, ExtractResolutions: true})
+
+	result, err := c.QueryDomain(context.Background(), "example.com")
+	if err != nil {
+		t.Fatalf("QueryDomain failed: %v", err)
+	}
+
+	if len(requested) != 2 {
+		t.Errorf("Expected only the selected relationships to be fetched, got %v", requested)
+	}
+	if len(result.Subdomains) != 1 || result.Subdomains[0] != "www.example.com" {
+		t.Errorf("Unexpected subdomains: %v", result.Subdomains)
+	}
+	if len(result.Resolutions) != 1 || result.Resolutions[0].LastResolved != "2024-01-02 03:04:05" {
+		t.Errorf("Unexpected resolutions: %+v", result.Resolutions)
+	}
+}
+
+func TestParseExtracts(t *testing.T) {
+	set, err := ParseExtracts("detected_urls, siblings")
+	if err != nil {
+		t.Fatalf("ParseExtracts failed: %v", err)
+	}
+	if !set.Has(ExtractDetectedURLs) || !set.Has(ExtractSiblings) || set.Has(ExtractUndetectedURLs) {
+		t.Errorf("Unexpected extract set: %v", set)
+	}
+
+	set, err = ParseExtracts("all")
+	if err != nil {
+		t.Fatalf("ParseExtracts failed: %v", err)
+	}
+	if len(set) != len(AllExtracts) {
+		t.Errorf("Expected all extracts, got %v", set)
+	}
+
+	if _, err := ParseExtracts("whois"); err == nil {
+		t.Error("Expected error for unknown extract")
+	}
+	if _, err := ParseExtracts(""); err == nil {
+		t.Error("Expected error for empty extract list")
+	}
+}
+
+func TestExtractSet_Filter(t *testing.T) {
+	result := &DomainResult{
+		Domain:         "example.com",
+		UndetectedURLs: []UndetectedURL{{URL: "http://example.com/a"}},
+		Subdomains:     []string{"www.example.com"},
+		Resolutions:    []Resolution{{IPAddress: "192.0.2.1"}},
+	}
+
+	filtered := DefaultExtracts().Filter(result)
+	if len(filtered.UndetectedURLs) != 1 || filtered.Subdomains != nil || filtered.Resolutions != nil {
+		t.Errorf("Expected only undetected URLs, got %+v", filtered)
+	}
+	if len(result.Subdomains) != 1 {
+		t.Error("Filter must not modify the original result")
+	}
+	if DefaultExtracts().Filter(nil) != nil {
+		t.Error("Expected nil for a nil result")
+	}
+}
+
+func TestRequestsPerDomain(t *testing.T) {
+	all, _ := ParseExtracts("all")
+
+	tests := []struct {
+		version  APIVersion
+		extracts ExtractSet
+		want     int
+	}{
+		{APIv2, all, 1},
+		{APIv3, DefaultExtracts(), 2},
+		{APIv3, ExtractSet{ExtractUndetectedURLs: true, ExtractDetectedURLs: true}, 2},
+		{APIv3, all, 7},
+	}
+
+	for _, tt := range tests {
+		if got := RequestsPerDomain(tt.version, tt.extracts); got != tt.want {
+			t.Errorf("RequestsPerDomain(%s, %v) = %d, want %d", tt.version, tt.extracts, got, tt.want)
+		}
+	}
+}
+
+func TestQueryDomain_TransportErrorRedactsKey(t *testing.T) {
+	// A closed server makes every request fail with a *url.Error, whose
+	// message includes the request URL
+	c := newTestClient(t, http.NotFoundHandler(), APIv2)
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closed.Close()
+	c.v2URL = closed.URL + "/vtapi/v2/domain/report"
+	c.v3URL = closed.URL + "/api/v3"
+	c.SetRetryPolicy(RetryPolicy{MaxAttempts: 1})
+
+	_, err := c.QueryDomain(context.Background(), "example.com")
+	if err == nil {
+		t.Fatal("Expected transport error")
+	}
+	if strings.Contains(err.Error(), testAPIKey) {
+		t.Errorf("Error leaks the API key: %v", err)
+	}
+	if !strings.Contains(err.Error(), "apikey=****4add") {
+		t.Errorf("Expected masked key in error, got %v", err)
+	}
+
+	_, err = c.CheckKey(context.Background(), testAPIKey)
+	if err == nil {
+		t.Fatal("Expected transport error")
+	}
+	if strings.Contains(err.Error(), testAPIKey) {
+		t.Errorf("Error leaks the API key: %v", err)
+	}
+}
@@ -0,0 +1,181 @@
+package limiter
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// LedgerEntry is the persisted usage of one API key. Day and Month name the
+// UTC period the counts belong to, so stale counts are reset when read.
+type LedgerEntry struct {
+	Day     string `json:"day"`
+	Daily   int    `json:"daily"`
+	Month   string `json:"month"`
+	Monthly int    `json:"monthly"`
+}
+
+// ledgerFile is the on-disk form of a Ledger.
+type ledgerFile struct {
+	Keys map[string]*LedgerEntry `json:"keys"`
+}
+
+// Ledger persists the daily and monthly request counts of each API key in a
+// state file, so quotas carry over between runs. Entries are keyed by KeyID
+// and never hold the key itself. Every access takes a lock on a companion
+// .lock file, which lets concurrent tyvt processes share one ledger.
+type Ledger struct {
+	path string
+}
+
+// DefaultLedgerPath returns the ledger location in the user's cache
+// directory, or an empty string if there is none.
+func DefaultLedgerPath() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "tyvt", "quota.json")
+}
+
+// OpenLedger returns the ledger stored at path, creating its directory.
+// The file itself is created on the first update.
+func OpenLedger(path string) (*Ledger, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, fmt.Errorf("failed to create ledger directory: %w", err)
+	}
+	return &Ledger{path: path}, nil
+}
+
+// Path returns the location of the ledger file.
+func (l *Ledger) Path() string {
+	return l.path
+}
+
+// KeyID returns the identifier an API key is stored under: the first 16 hex
+// digits of its SHA-256 hash.
+func KeyID(apiKey string) string {
+	sum := sha256.Sum256([]byte(apiKey))
+	return hex.EncodeToString(sum[:8])
+}
+
+// Load returns the entries of all keys, with counts of past periods reset
+// as of now.
+func (l *Ledger) Load(now time.Time) (map[string]*LedgerEntry, error) {
+	var entries map[string]*LedgerEntry
+
+	err := l.locked(false, func() error {
+		var err error
+		entries, err = l.read()
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, entry := range entries {
+		entry.roll(now)
+	}
+
+	return entries, nil
+}
+
+// Update calls fn with the entry of apiKey, current as of now, and saves
+// the ledger if fn succeeds. The ledger stays locked while fn runs, so
+// check-and-increment by several processes cannot overlap.
+func (l *Ledger) Update(apiKey string, now time.Time, fn func(entry *LedgerEntry) error) error {
+	return l.locked(true, func() error {
+		entries, err := l.read()
+		if err != nil {
+			return err
+		}
+
+		id := KeyID(apiKey)
+		entry, exists := entries[id]
+		if !exists {
+			entry = &LedgerEntry{}
+			entries[id] = entry
+		}
+		entry.roll(now)
+
+		if err := fn(entry); err != nil {
+			return err
+		}
+
+		return l.write(entries)
+	})
+}
+
+// roll resets the counts of an entry whose day or month has passed.
+func (e *LedgerEntry) roll(now time.Time) {
+	now = now.UTC()
+
+	if day := now.Format("2006-01-02"); e.Day != day {
+		e.Day = day
+		e.Daily = 0
+	}
+	if month := now.Format("2006-01"); e.Month != month {
+		e.Month = month
+		e.Monthly = 0
+	}
+}
+
+// locked runs fn while holding a shared or exclusive lock on the ledger.
+func (l *Ledger) locked(exclusive bool, fn func() error) error {
+	lock, err := os.OpenFile(l.path+".lock", os.O_CREATE|os.O_RDWR, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open ledger lock: %w", err)
+	}
+	defer lock.Close()
+
+	if err := lockFile(lock, exclusive); err != nil {
+		return fmt.Errorf("failed to lock ledger: %w", err)
+	}
+	defer unlockFile(lock)
+
+	return fn()
+}
+
+func (l *Ledger) read() (map[string]*LedgerEntry, error) {
+	data, err := os.ReadFile(l.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return make(map[string]*LedgerEntry), nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read ledger: %w", err)
+	}
+
+	var file ledgerFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to decode ledger %s: %w", l.path, err)
+	}
+	if file.Keys == nil {
+		file.Keys = make(map[string]*LedgerEntry)
+	}
+
+	return file.Keys, nil
+}
+
+// write replaces the ledger file through a temporary file, so that a crash
+// never leaves it half written.
+func (l *Ledger) write(entries map[string]*LedgerEntry) error {
+	data, err := json.MarshalIndent(ledgerFile{Keys: entries}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode ledger: %w", err)
+	}
+
+	tmp := l.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("failed to write ledger: %w", err)
+	}
+	if err := os.Rename(tmp, l.path); err != nil {
+		return fmt.Errorf("failed to replace ledger: %w", err)
+	}
+
+	return nil
+}
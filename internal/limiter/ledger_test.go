@@ -0,0 +1,162 @@
+package limiter
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestLedger_PersistsAcrossLimiters(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "quota.json")
+	ctx := context.Background()
+	testKey := "test-api-key"
+
+	for run := 0; run < 2; run++ {
+		ledger, err := OpenLedger(path)
+		if err != nil {
+			t.Fatalf("OpenLedger failed: %v", err)
+		}
+
+		rl := New(time.Millisecond)
+		if err := rl.SetLedger(ledger); err != nil {
+			t.Fatalf("SetLedger failed: %v", err)
+		}
+		for i := 0; i < 3; i++ {
+			if err := rl.Wait(ctx, testKey); err != nil {
+				t.Fatalf("Wait failed: %v", err)
+			}
+		}
+	}
+
+	rl := New(time.Millisecond)
+	ledger, _ := OpenLedger(path)
+	rl.SetLedger(ledger)
+	rl.NextAvailable(testKey)
+
+	daily, monthly := rl.GetQuotaStatus(testKey)
+	if daily != 6 || monthly != 6 {
+		t.Errorf("Expected 6 requests carried over, got %d daily, %d monthly", daily, monthly)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read ledger: %v", err)
+	}
+	if strings.Contains(string(data), testKey) {
+		t.Error("Ledger must not contain the raw API key")
+	}
+	if !strings.Contains(string(data), KeyID(testKey)) {
+		t.Error("Expected ledger entry keyed by KeyID")
+	}
+}
+
+func TestLedger_SharedQuota(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "quota.json")
+	ctx := context.Background()
+	testKey := "test-api-key"
+	limits := Limits{Interval: 0, Burst: 1, Daily: 20, Monthly: MonthlyLimit}
+
+	// Each limiter stands in for a separate process with its own view of
+	// the counts; only the ledger is shared.
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	granted := 0
+
+	for p := 0; p < 4; p++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			ledger, err := OpenLedger(path)
+			if err != nil {
+				t.Errorf("OpenLedger failed: %v", err)
+				return
+			}
+			rl := NewWithLimits(limits)
+			rl.SetLedger(ledger)
+
+			for i := 0; i < 10; i++ {
+				if err := rl.Wait(ctx, testKey); err == nil {
+					mu.Lock()
+					granted++
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if granted != limits.Daily {
+		t.Errorf("Expected exactly %d requests across processes, got %d", limits.Daily, granted)
+	}
+}
+
+func TestLedgerEntry_RollsOverDay(t *testing.T) {
+	entry := &LedgerEntry{Day: "2024-01-31", Daily: 10, Month: "2024-01", Monthly: 100}
+
+	entry.roll(time.Date(2024, 2, 1, 9, 0, 0, 0, time.UTC))
+	if entry.Daily != 0 || entry.Monthly != 0 {
+		t.Errorf("Expected counts reset in a new day and month, got %+v", entry)
+	}
+
+	entry.Daily, entry.Monthly = 5, 5
+	entry.roll(time.Date(2024, 2, 1, 23, 0, 0, 0, time.UTC))
+	if entry.Daily != 5 || entry.Monthly != 5 {
+		t.Errorf("Expected counts kept within the same day, got %+v", entry)
+	}
+}
+
+func TestLedger_LockDoesNotBlockOtherKeys(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "quota.json")
+	ledger, err := OpenLedger(path)
+	if err != nil {
+		t.Fatalf("OpenLedger failed: %v", err)
+	}
+
+	rl := New(time.Millisecond)
+	if err := rl.SetLedger(ledger); err != nil {
+		t.Fatalf("SetLedger failed: %v", err)
+	}
+
+	// Hold the ledger lock as another process would
+	lock, err := os.OpenFile(path+".lock", os.O_CREATE|os.O_RDWR, 0600)
+	if err != nil {
+		t.Fatalf("Failed to open lock: %v", err)
+	}
+	defer lock.Close()
+	if err := lockFile(lock, true); err != nil {
+		t.Fatalf("Failed to lock ledger: %v", err)
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- rl.Wait(context.Background(), "key-a") }()
+	time.Sleep(20 * time.Millisecond)
+
+	// While key-a waits for the ledger, the limiter still answers for
+	// every key
+	answered := make(chan struct{})
+	go func() {
+		rl.NextAvailable("key-b")
+		rl.Remaining("key-a")
+		rl.GetQuotaStatus("key-b")
+		close(answered)
+	}()
+
+	select {
+	case <-answered:
+	case <-time.After(time.Second):
+		t.Fatal("Limiter blocked while the ledger was locked")
+	}
+
+	unlockFile(lock)
+	if err := <-done; err != nil {
+		t.Fatalf("Wait failed: %v", err)
+	}
+	if daily, _ := rl.GetQuotaStatus("key-a"); daily != 1 {
+		t.Errorf("Expected the request to be counted once the ledger was free, got %d", daily)
+	}
+}
@@ -0,0 +1,16 @@
+//go:build !unix
+
+package limiter
+
+import "os"
+
+// Without flock the ledger is not protected against concurrent processes;
+// a single tyvt process still serializes its own updates.
+
+func lockFile(f *os.File, exclusive bool) error {
+	return nil
+}
+
+func unlockFile(f *os.File) error {
+	return nil
+}
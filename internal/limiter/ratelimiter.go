@@ -7,18 +7,41 @@ import (
 	"time"
 )
 
+// KeyQuota is the usage of a single API key. Tokens is the key's token
+// bucket, last refilled at LastRefill; every request spends one token.
+// After the API rate limited the key, it is held back until ThrottledUntil.
 type KeyQuota struct {
-	DailyCount   int
-	MonthlyCount int
-	LastReset    time.Time
-	MonthReset   time.Time
+	DailyCount     int
+	MonthlyCount   int
+	LastReset      time.Time
+	MonthReset     time.Time
+	Tokens         float64
+	LastRefill     time.Time
+	ThrottledUntil time.Time
 }
 
+// Limits is the quota an API key is held to. A key earns one request
+// token per Interval and can save up to Burst of them, which enforces the
+// per-minute limit; Daily and Monthly cap the number of requests.
+type Limits struct {
+	Interval time.Duration
+	Burst    int
+	Daily    int
+	Monthly  int
+}
+
+// RateLimiter enforces the limits of each API key independently, so
+// requests made with different keys never wait for each other. Keys are
+// held to the default limits unless given their own with SetKeyLimits. With a
+// ledger, the daily and monthly counts also include earlier runs and other
+// processes sharing it.
 type RateLimiter struct {
-	mu          sync.Mutex
-	lastRequest time.Time
-	minInterval time.Duration
-	keyQuotas   map[string]*KeyQuota
+	mu        sync.Mutex
+	limits    Limits
+	keyLimits map[string]Limits
+	keyQuotas map[string]*KeyQuota
+	ledger    *Ledger
+	persisted map[string]*LedgerEntry
 }
 
 const (
@@ -26,28 +49,91 @@ const (
 	MonthlyLimit = 15500
 )
 
+// PerMinute returns the token interval that allows requests per minute.
+func PerMinute(requests int) time.Duration {
+	return time.Minute / time.Duration(requests)
+}
+
+// New creates a limiter that spaces the requests of each key at least
+// minInterval apart, within the default daily and monthly limits.
 func New(minInterval time.Duration) *RateLimiter {
+	return NewWithLimits(Limits{
+		Interval: minInterval,
+		Burst:    1,
+		Daily:    DailyLimit,
+		Monthly:  MonthlyLimit,
+	})
+}
+
+// NewWithLimits creates a limiter that holds every key to limits by
+// default. A Burst below 1 is treated as 1.
+func NewWithLimits(limits Limits) *RateLimiter {
 	return &RateLimiter{
-		minInterval: minInterval,
-		keyQuotas:   make(map[string]*KeyQuota),
+		limits:    limits.normalized(),
+		keyLimits: make(map[string]Limits),
+		keyQuotas: make(map[string]*KeyQuota),
 	}
 }
 
-// checkQuota verifies if a request can be made for the given API key
-// without exceeding daily or monthly limits. Assumes mutex is already held.
-// This is the single source of truth for quota checking logic.
-func (rl *RateLimiter) checkQuota(apiKey string) error {
+func (l Limits) normalized() Limits {
+	if l.Burst < 1 {
+		l.Burst = 1
+	}
+	return l
+}
+
+// SetKeyLimits holds apiKey to limits instead of the default limits.
+func (rl *RateLimiter) SetKeyLimits(apiKey string, limits Limits) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	rl.keyLimits[apiKey] = limits.normalized()
+}
+
+// limitsFor returns the limits apiKey is held to. Assumes mutex is already
+// held.
+func (rl *RateLimiter) limitsFor(apiKey string) Limits {
+	if limits, ok := rl.keyLimits[apiKey]; ok {
+		return limits
+	}
+	return rl.limits
+}
+
+// SetLedger persists every request in ledger and counts the requests it
+// already records towards each key's daily and monthly limits.
+func (rl *RateLimiter) SetLedger(ledger *Ledger) error {
+	entries, err := ledger.Load(time.Now())
+	if err != nil {
+		return err
+	}
+
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	rl.ledger = ledger
+	rl.persisted = entries
+
+	return nil
+}
+
+// quota returns the usage of apiKey, creating it with a full bucket, and
+// applies the daily and monthly resets and the bucket refill due by now.
+// Assumes mutex is already held.
+func (rl *RateLimiter) quota(apiKey string, now time.Time) *KeyQuota {
+	limits := rl.limitsFor(apiKey)
 	quota, exists := rl.keyQuotas[apiKey]
 	if !exists {
 		quota = &KeyQuota{
-			LastReset:  time.Now().Truncate(24 * time.Hour),
-			MonthReset: time.Date(time.Now().Year(), time.Now().Month(), 1, 0, 0, 0, 0, time.UTC),
+			LastReset:  now.Truncate(24 * time.Hour),
+			MonthReset: time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC),
+			Tokens:     float64(limits.Burst),
+			LastRefill: now,
+		}
+		if entry, ok := rl.persisted[KeyID(apiKey)]; ok {
+			quota.DailyCount = entry.Daily
+			quota.MonthlyCount = entry.Monthly
 		}
 		rl.keyQuotas[apiKey] = quota
 	}
 
-	now := time.Now()
-
 	// Reset daily quota if 24 hours have passed
 	if now.Sub(quota.LastReset) >= 24*time.Hour {
 		quota.DailyCount = 0
@@ -60,81 +146,240 @@ func (rl *RateLimiter) checkQuota(apiKey string) error {
 		quota.MonthReset = time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
 	}
 
-	// Check if limits would be exceeded
-	if quota.DailyCount >= DailyLimit {
-		return fmt.Errorf("daily quota exceeded for key (500/day)")
+	if limits.Interval <= 0 {
+		quota.Tokens = float64(limits.Burst)
+	} else if elapsed := now.Sub(quota.LastRefill); elapsed > 0 {
+		quota.Tokens += float64(elapsed) / float64(limits.Interval)
+		if quota.Tokens > float64(limits.Burst) {
+			quota.Tokens = float64(limits.Burst)
+		}
+	}
+	quota.LastRefill = now
+
+	return quota
+}
+
+// QuotaError reports that a key has used up its daily or monthly quota.
+// Period is "day" or "month".
+type QuotaError struct {
+	Period string
+	Limit  int
+}
+
+func (e *QuotaError) Error() string {
+	if e.Period == "month" {
+		return fmt.Sprintf("monthly quota exceeded for key (%d/month)", e.Limit)
+	}
+	return fmt.Sprintf("daily quota exceeded for key (%d/day)", e.Limit)
+}
+
+// checkQuota verifies if a request can be made for the given API key
+// without exceeding its daily or monthly limits.
+// This is the single source of truth for quota checking logic.
+func checkQuota(limits Limits, quota *KeyQuota) error {
+	if quota.DailyCount >= limits.Daily {
+		return &QuotaError{Period: "day", Limit: limits.Daily}
 	}
 
-	if quota.MonthlyCount >= MonthlyLimit {
-		return fmt.Errorf("monthly quota exceeded for key (15,500/month)")
+	if quota.MonthlyCount >= limits.Monthly {
+		return &QuotaError{Period: "month", Limit: limits.Monthly}
 	}
 
 	return nil
 }
 
-// Wait blocks until it's safe to make a request, respecting both
-// rate limiting intervals and API quota limits.
-func (rl *RateLimiter) Wait(ctx context.Context, apiKey string) error {
+// tokenWait returns how long until the key is no longer throttled and its
+// bucket holds a whole token.
+func tokenWait(limits Limits, quota *KeyQuota, now time.Time) time.Duration {
+	if throttled := quota.ThrottledUntil.Sub(now); throttled > 0 {
+		return throttled
+	}
+	if quota.Tokens >= 1 {
+		return 0
+	}
+	return time.Duration((1 - quota.Tokens) * float64(limits.Interval))
+}
+
+// Throttle holds apiKey back for d after the API signalled that it is
+// making requests too fast. The key's bucket is emptied, so requests only
+// resume at its regular pace once d has passed.
+func (rl *RateLimiter) Throttle(apiKey string, d time.Duration) {
 	rl.mu.Lock()
+	defer rl.mu.Unlock()
 
-	// Check quota first while we have the lock
-	if err := rl.checkQuota(apiKey); err != nil {
-		rl.mu.Unlock()
-		return err
+	now := time.Now()
+	quota := rl.quota(apiKey, now)
+	quota.Tokens = 0
+	if until := now.Add(d); until.After(quota.ThrottledUntil) {
+		quota.ThrottledUntil = until
 	}
+}
 
-	now := time.Now()
-	var waitTime time.Duration
+// Wait blocks until it's safe to make a request with apiKey, respecting
+// both the key's token bucket and its API quota limits.
+func (rl *RateLimiter) Wait(ctx context.Context, apiKey string) error {
+	for {
+		rl.mu.Lock()
+		now := time.Now()
+		quota := rl.quota(apiKey, now)
+		limits := rl.limitsFor(apiKey)
 
-	// Calculate how long we need to wait based on last request time
-	if !rl.lastRequest.IsZero() {
-		elapsed := now.Sub(rl.lastRequest)
-		if elapsed < rl.minInterval {
-			waitTime = rl.minInterval - elapsed
+		if err := checkQuota(limits, quota); err != nil {
+			rl.mu.Unlock()
+			return err
 		}
-	}
 
-	rl.mu.Unlock()
+		waitTime := tokenWait(limits, quota, now)
+		if waitTime <= 0 {
+			// The token is taken before the ledger is updated, so that
+			// concurrent callers keep to the key's pace meanwhile
+			quota.Tokens--
+			ledger := rl.ledger
+			if ledger == nil {
+				quota.DailyCount++
+				quota.MonthlyCount++
+			}
+			rl.mu.Unlock()
+
+			if ledger == nil {
+				return nil
+			}
+			return rl.record(ledger, apiKey, limits, now)
+		}
 
-	// Wait outside the lock to allow other goroutines to proceed
-	if waitTime > 0 {
+		rl.mu.Unlock()
+
+		// Wait outside the lock to allow other goroutines to proceed, then
+		// try again as another caller may have taken the token meanwhile
 		select {
 		case <-ctx.Done():
 			return ctx.Err()
 		case <-time.After(waitTime):
 		}
 	}
+}
+
+// record counts a request with apiKey in ledger, which may hold requests
+// made by other processes since the last one, and refunds the token taken
+// for it if the ledger shows the quota used up. The ledger is locked and
+// rewritten without holding the mutex, so that a slow disk or another
+// process holding the ledger lock never stalls the other keys.
+func (rl *RateLimiter) record(ledger *Ledger, apiKey string, limits Limits, now time.Time) error {
+	var daily, monthly int
+	err := ledger.Update(apiKey, now, func(entry *LedgerEntry) error {
+		daily, monthly = entry.Daily, entry.Monthly
+		if err := checkQuota(limits, &KeyQuota{DailyCount: daily, MonthlyCount: monthly}); err != nil {
+			return err
+		}
+
+		entry.Daily++
+		entry.Monthly++
+		daily, monthly = entry.Daily, entry.Monthly
+		return nil
+	})
 
-	// Update counters after wait
 	rl.mu.Lock()
-	rl.lastRequest = time.Now()
+	defer rl.mu.Unlock()
 
-	quota := rl.keyQuotas[apiKey]
-	quota.DailyCount++
-	quota.MonthlyCount++
-	rl.mu.Unlock()
+	// Concurrent updates may finish out of order, so a count only grows
+	quota := rl.quota(apiKey, time.Now())
+	quota.DailyCount = max(quota.DailyCount, daily)
+	quota.MonthlyCount = max(quota.MonthlyCount, monthly)
 
-	return nil
+	if err != nil {
+		quota.Tokens = min(quota.Tokens+1, float64(limits.Burst))
+	}
+
+	return err
 }
 
-// GetQuotaStatus returns the current quota usage for an API key.
-// This is useful for monitoring and logging.
-func (rl *RateLimiter) GetQuotaStatus(apiKey string) (dailyUsed, monthlyUsed int) {
+// NextAvailable returns when a request with apiKey can be made next: now if
+// it is ready, the next refill of its bucket if it is only paced, or the
+// reset of its daily or monthly quota if that is exhausted.
+func (rl *RateLimiter) NextAvailable(apiKey string) time.Time {
 	rl.mu.Lock()
 	defer rl.mu.Unlock()
 
-	quota, exists := rl.keyQuotas[apiKey]
-	if !exists {
-		return 0, 0
+	now := time.Now()
+	quota := rl.quota(apiKey, now)
+	limits := rl.limitsFor(apiKey)
+
+	if quota.MonthlyCount >= limits.Monthly {
+		return quota.MonthReset.AddDate(0, 1, 0)
+	}
+	if quota.DailyCount >= limits.Daily {
+		return quota.LastReset.Add(24 * time.Hour)
 	}
 
+	return now.Add(tokenWait(limits, quota, now))
+}
+
+// Interval returns how often apiKey earns a request token.
+func (rl *RateLimiter) Interval(apiKey string) time.Duration {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	return rl.limitsFor(apiKey).Interval
+}
+
+// Remaining returns how many more requests apiKey may make today and this
+// month.
+func (rl *RateLimiter) Remaining(apiKey string) (daily, monthly int) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	quota := rl.quota(apiKey, time.Now())
+	limits := rl.limitsFor(apiKey)
+
+	return max(limits.Daily-quota.DailyCount, 0), max(limits.Monthly-quota.MonthlyCount, 0)
+}
+
+// Soonest returns the key among keys that can make a request first and when
+// it can. It returns an empty key if keys is empty.
+func (rl *RateLimiter) Soonest(keys []string) (string, time.Time) {
+	var soonest string
+	var at time.Time
+
+	for _, key := range keys {
+		next := rl.NextAvailable(key)
+		if soonest == "" || next.Before(at) {
+			soonest, at = key, next
+		}
+	}
+
+	return soonest, at
+}
+
+// GetQuotaStatus returns the current quota usage for an API key, including
+// the requests recorded in the ledger, if one is set.
+// This is useful for monitoring and logging.
+func (rl *RateLimiter) GetQuotaStatus(apiKey string) (dailyUsed, monthlyUsed int) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	quota := rl.quota(apiKey, time.Now())
 	return quota.DailyCount, quota.MonthlyCount
 }
 
+// Limits returns the limits apiKey is held to.
+func (rl *RateLimiter) Limits(apiKey string) Limits {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	return rl.limitsFor(apiKey)
+}
+
+// Resets returns when the daily and monthly counts of apiKey are next
+// reset: at midnight and on the first of the month, in UTC.
+func (rl *RateLimiter) Resets(apiKey string) (daily, monthly time.Time) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	quota := rl.quota(apiKey, time.Now())
+	return quota.LastReset.Add(24 * time.Hour), quota.MonthReset.AddDate(0, 1, 0)
+}
+
 // Reset clears the rate limiter state. Primarily used for testing.
 func (rl *RateLimiter) Reset() {
 	rl.mu.Lock()
 	defer rl.mu.Unlock()
-	rl.lastRequest = time.Time{}
 	rl.keyQuotas = make(map[string]*KeyQuota)
 }
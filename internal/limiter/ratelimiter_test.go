@@ -2,6 +2,7 @@ package limiter
 
 import (
 	"context"
+	"errors"
 	"testing"
 	"time"
 )
@@ -27,6 +28,22 @@ func TestRateLimiter_Wait(t *testing.T) {
 	}
 }
 
+func TestRateLimiter_KeysArePacedIndependently(t *testing.T) {
+	rl := New(time.Second)
+	ctx := context.Background()
+
+	start := time.Now()
+	for _, key := range []string{"key-1", "key-2", "key-3"} {
+		if err := rl.Wait(ctx, key); err != nil {
+			t.Errorf("Wait for %s should not error: %v", key, err)
+		}
+	}
+
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("Expected first request of each key without delay, took %v", elapsed)
+	}
+}
+
 func TestRateLimiter_ContextCancellation(t *testing.T) {
 	rl := New(time.Second)
 	ctx, cancel := context.WithCancel(context.Background())
@@ -120,4 +137,143 @@ func TestRateLimiter_GetQuotaStatus_NonexistentKey(t *testing.T) {
 	if daily != 0 || monthly != 0 {
 		t.Errorf("Expected 0,0 for nonexistent key, got %d,%d", daily, monthly)
 	}
-}
\ No newline at end of file
+}
+
+func TestRateLimiter_Burst(t *testing.T) {
+	rl := NewWithLimits(Limits{Interval: time.Second, Burst: 3, Daily: DailyLimit, Monthly: MonthlyLimit})
+	ctx := context.Background()
+	testKey := "test-api-key"
+
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		if err := rl.Wait(ctx, testKey); err != nil {
+			t.Errorf("Request %d failed: %v", i+1, err)
+		}
+	}
+
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("Expected the burst to pass without delay, took %v", elapsed)
+	}
+
+	next := rl.NextAvailable(testKey)
+	if wait := time.Until(next); wait < 500*time.Millisecond || wait > time.Second {
+		t.Errorf("Expected next token in about 1s once the bucket is empty, got %v", wait)
+	}
+}
+
+func TestRateLimiter_NextAvailable_DailyQuota(t *testing.T) {
+	rl := New(time.Millisecond)
+	testKey := "test-api-key"
+
+	if next := rl.NextAvailable(testKey); time.Until(next) > 0 {
+		t.Errorf("Expected unused key to be ready now, got %v", next)
+	}
+
+	rl.mu.Lock()
+	quota := rl.keyQuotas[testKey]
+	quota.DailyCount = DailyLimit
+	rl.mu.Unlock()
+
+	next := rl.NextAvailable(testKey)
+	if !next.Equal(quota.LastReset.Add(24 * time.Hour)) {
+		t.Errorf("Expected exhausted key to be available at the daily reset, got %v", next)
+	}
+}
+
+func TestRateLimiter_Soonest(t *testing.T) {
+	rl := New(time.Hour)
+	ctx := context.Background()
+
+	if err := rl.Wait(ctx, "busy-key"); err != nil {
+		t.Fatalf("Wait should not error: %v", err)
+	}
+
+	key, at := rl.Soonest([]string{"busy-key", "idle-key"})
+	if key != "idle-key" {
+		t.Errorf("Expected idle-key to be ready first, got %s", key)
+	}
+	if time.Until(at) > 0 {
+		t.Errorf("Expected idle-key to be ready now, got %v", at)
+	}
+}
+
+func TestRateLimiter_SetKeyLimits(t *testing.T) {
+	rl := New(time.Millisecond)
+	ctx := context.Background()
+
+	rl.SetKeyLimits("small-key", Limits{Interval: time.Millisecond, Daily: 2, Monthly: MonthlyLimit})
+
+	for i := 0; i < 2; i++ {
+		if err := rl.Wait(ctx, "small-key"); err != nil {
+			t.Fatalf("Request %d failed: %v", i+1, err)
+		}
+	}
+	if err := rl.Wait(ctx, "small-key"); err == nil || err.Error() != "daily quota exceeded for key (2/day)" {
+		t.Errorf("Expected the key's own daily limit to apply, got %v", err)
+	}
+
+	for i := 0; i < 3; i++ {
+		if err := rl.Wait(ctx, "default-key"); err != nil {
+			t.Errorf("Default limits should still apply to other keys: %v", err)
+		}
+	}
+}
+
+func TestRateLimiter_Remaining(t *testing.T) {
+	rl := New(time.Millisecond)
+	ctx := context.Background()
+	testKey := "test-api-key"
+
+	rl.SetKeyLimits(testKey, Limits{Interval: time.Millisecond, Daily: 2, Monthly: 10})
+
+	if daily, monthly := rl.Remaining(testKey); daily != 2 || monthly != 10 {
+		t.Errorf("Expected full quota for unused key, got %d, %d", daily, monthly)
+	}
+
+	rl.Wait(ctx, testKey)
+	rl.Wait(ctx, testKey)
+
+	if daily, monthly := rl.Remaining(testKey); daily != 0 || monthly != 8 {
+		t.Errorf("Expected 0 daily and 8 monthly requests left, got %d, %d", daily, monthly)
+	}
+
+	var quotaErr *QuotaError
+	if err := rl.Wait(ctx, testKey); !errors.As(err, &quotaErr) || quotaErr.Period != "day" {
+		t.Errorf("Expected daily QuotaError, got %v", err)
+	}
+}
+
+func TestRateLimiter_Resets(t *testing.T) {
+	rl := New(time.Millisecond)
+
+	daily, monthly := rl.Resets("test-api-key")
+
+	now := time.Now().UTC()
+	wantDaily := time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, time.UTC)
+	wantMonthly := time.Date(now.Year(), now.Month()+1, 1, 0, 0, 0, 0, time.UTC)
+
+	if !daily.Equal(wantDaily) {
+		t.Errorf("Expected daily reset at %v, got %v", wantDaily, daily)
+	}
+	if !monthly.Equal(wantMonthly) {
+		t.Errorf("Expected monthly reset at %v, got %v", wantMonthly, monthly)
+	}
+}
+
+func TestRateLimiter_Throttle(t *testing.T) {
+	rl := New(time.Millisecond)
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	rl.Throttle("slow-key", time.Minute)
+
+	if wait := time.Until(rl.NextAvailable("slow-key")); wait < 55*time.Second {
+		t.Errorf("Expected throttled key to be available in about a minute, got %v", wait)
+	}
+	if err := rl.Wait(ctx, "slow-key"); err != context.DeadlineExceeded {
+		t.Errorf("Expected Wait to block while throttled, got %v", err)
+	}
+	if err := rl.Wait(ctx, "other-key"); err != nil {
+		t.Errorf("Throttling one key should not slow down others: %v", err)
+	}
+}
@@ -0,0 +1,116 @@
+package rotator
+
+import (
+	"net/http"
+	"time"
+)
+
+// KeyState is the health of an API key as seen from its latest responses.
+type KeyState string
+
+const (
+	// KeyActive keys answered their latest request normally.
+	KeyActive KeyState = "active"
+	// KeyRateLimited keys were last told to slow down (HTTP 204 or 429).
+	KeyRateLimited KeyState = "rate-limited"
+	// KeyForbidden keys were last refused access (HTTP 403), as revoked
+	// keys are.
+	KeyForbidden KeyState = "forbidden"
+	// KeyInvalid keys were last rejected as wrong credentials (HTTP 401).
+	KeyInvalid KeyState = "invalid"
+)
+
+// QuarantineAfter is the number of consecutive authentication failures
+// after which a key is quarantined and no longer selected.
+const QuarantineAfter = 3
+
+// KeyHealth is the health record of one API key.
+type KeyHealth struct {
+	Key          string
+	State        KeyState
+	Requests     int
+	Failures     int // consecutive authentication failures
+	Quarantined  bool
+	LastStatus   int
+	LastResponse time.Time
+}
+
+// stateFor classifies an HTTP status. Statuses that say nothing about the
+// key, such as server errors, keep the previous state.
+func stateFor(status int, previous KeyState) KeyState {
+	switch {
+	case status == http.StatusUnauthorized:
+		return KeyInvalid
+	case status == http.StatusForbidden:
+		return KeyForbidden
+	case status == http.StatusNoContent || status == http.StatusTooManyRequests:
+		return KeyRateLimited
+	case status < http.StatusInternalServerError:
+		return KeyActive
+	}
+	return previous
+}
+
+// SetQuarantineHandler sets a function called once for each key when it is
+// quarantined, such as to log it.
+func (kr *KeyRotator) SetQuarantineHandler(handler func(health KeyHealth)) {
+	kr.mu.Lock()
+	defer kr.mu.Unlock()
+	kr.onQuarantine = handler
+}
+
+// ReportStatus records the HTTP status of a response to a request made with
+// apiKey. A key is quarantined after QuarantineAfter authentication
+// failures in a row.
+func (kr *KeyRotator) ReportStatus(apiKey string, status int) {
+	kr.mu.Lock()
+
+	health := kr.health[apiKey]
+	if health == nil {
+		kr.mu.Unlock()
+		return
+	}
+
+	health.Requests++
+	health.LastStatus = status
+	health.LastResponse = time.Now()
+	health.State = stateFor(status, health.State)
+
+	quarantined := false
+	if health.State == KeyInvalid || health.State == KeyForbidden {
+		health.Failures++
+		if health.Failures >= QuarantineAfter && !health.Quarantined {
+			health.Quarantined = true
+			quarantined = true
+		}
+	} else if health.State == KeyActive {
+		health.Failures = 0
+	}
+
+	snapshot := *health
+	handler := kr.onQuarantine
+	kr.mu.Unlock()
+
+	if quarantined && handler != nil {
+		handler(snapshot)
+	}
+}
+
+// Health returns the health of every key, in the order the keys were given.
+func (kr *KeyRotator) Health() []KeyHealth {
+	kr.mu.RLock()
+	defer kr.mu.RUnlock()
+
+	health := make([]KeyHealth, 0, len(kr.keys))
+	for _, key := range kr.keys {
+		health = append(health, *kr.health[key])
+	}
+	return health
+}
+
+// quarantined reports whether apiKey is quarantined. Assumes mutex is
+// already held.
+func (kr *KeyRotator) quarantined(apiKey string) bool {
+	health := kr.health[apiKey]
+	return health != nil && health.Quarantined
+}
@@ -0,0 +1,73 @@
+package rotator
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestReportStatus_States(t *testing.T) {
+	rotator := NewKeyRotator([]string{"key1"}, time.Second)
+
+	tests := []struct {
+		status int
+		want   KeyState
+	}{
+		{http.StatusOK, KeyActive},
+		{http.StatusNoContent, KeyRateLimited},
+		{http.StatusInternalServerError, KeyRateLimited},
+		{http.StatusNotFound, KeyActive},
+		{http.StatusTooManyRequests, KeyRateLimited},
+		{http.StatusUnauthorized, KeyInvalid},
+		{http.StatusForbidden, KeyForbidden},
+	}
+
+	for _, tt := range tests {
+		rotator.ReportStatus("key1", tt.status)
+		if got := rotator.Health()[0].State; got != tt.want {
+			t.Errorf("After status %d expected %s, got %s", tt.status, tt.want, got)
+		}
+	}
+
+	if requests := rotator.Health()[0].Requests; requests != len(tests) {
+		t.Errorf("Expected %d requests, got %d", len(tests), requests)
+	}
+}
+
+func TestReportStatus_Quarantine(t *testing.T) {
+	rotator := NewKeyRotator([]string{"key1", "key2"}, time.Second)
+	defer rotator.Stop()
+
+	var quarantined []string
+	rotator.SetQuarantineHandler(func(health KeyHealth) {
+		quarantined = append(quarantined, health.Key)
+	})
+
+	// A success in between resets the failure count
+	rotator.ReportStatus("key1", http.StatusForbidden)
+	rotator.ReportStatus("key1", http.StatusOK)
+	for i := 0; i < QuarantineAfter-1; i++ {
+		rotator.ReportStatus("key1", http.StatusForbidden)
+	}
+	if len(quarantined) != 0 {
+		t.Fatal("Key quarantined before reaching the failure threshold")
+	}
+
+	rotator.ReportStatus("key1", http.StatusForbidden)
+	rotator.ReportStatus("key1", http.StatusForbidden)
+	if len(quarantined) != 1 || quarantined[0] != "key1" {
+		t.Errorf("Expected key1 to be quarantined once, got %v", quarantined)
+	}
+
+	if key, err := rotator.SelectKey("key1"); err != nil || key != "key2" {
+		t.Errorf("Expected key2 instead of quarantined key1, got %s, %v", key, err)
+	}
+
+	for i := 0; i < QuarantineAfter; i++ {
+		rotator.ReportStatus("key2", http.StatusUnauthorized)
+	}
+	if _, err := rotator.SelectKey(""); !errors.Is(err, ErrAllQuarantined) {
+		t.Errorf("Expected ErrAllQuarantined, got %v", err)
+	}
+}
@@ -1,10 +1,35 @@
 package rotator
 
 import (
+	"errors"
+	"fmt"
 	"sync"
 	"time"
 )
 
+// QuotaSource reports how soon each key can make a request, how often it
+// earns one and how much of its quota is left. It is implemented by
+// limiter.RateLimiter.
+type QuotaSource interface {
+	NextAvailable(apiKey string) time.Time
+	Interval(apiKey string) time.Duration
+	Remaining(apiKey string) (daily, monthly int)
+}
+
+// ExhaustedError reports that every key has used up its quota. ResetAt is
+// when the first of them becomes available again.
+type ExhaustedError struct {
+	ResetAt time.Time
+}
+
+func (e *ExhaustedError) Error() string {
+	return fmt.Sprintf("all API keys have exhausted their quota; the earliest resets at %s (in %s)",
+		e.ResetAt.Format(time.RFC3339), time.Until(e.ResetAt).Round(time.Minute))
+}
+
+// ErrAllQuarantined is returned by SelectKey when every key is quarantined.
+var ErrAllQuarantined = errors.New("all API keys are quarantined after repeated authentication failures")
+
 type KeyRotator struct {
 	mu               sync.RWMutex
 	keys             []string
@@ -13,6 +38,9 @@ type KeyRotator struct {
 	lastRotation     time.Time
 	started          bool
 	stopChan         chan struct{}
+	quotas           QuotaSource
+	health           map[string]*KeyHealth
+	onQuarantine     func(health KeyHealth)
 }
 
 func NewKeyRotator(keys []string, rotationInterval time.Duration) *KeyRotator {
@@ -22,16 +50,43 @@ func NewKeyRotator(keys []string, rotationInterval time.Duration) *KeyRotator {
 		rotationInterval: rotationInterval,
 		lastRotation:     time.Now(),
 		stopChan:         make(chan struct{}),
+		health:           make(map[string]*KeyHealth, len(keys)),
+	}
+
+	for _, key := range keys {
+		kr.health[key] = &KeyHealth{Key: key, State: KeyActive}
 	}
 
 	if len(keys) > 1 {
+		kr.started = true
 		go kr.autoRotate()
 	}
 
 	return kr
 }
 
+// SetQuotaSource makes the rotator choose keys by their quota instead of
+// rotating on a timer; see SelectKey.
+func (kr *KeyRotator) SetQuotaSource(quotas QuotaSource) {
+	kr.Stop()
+
+	kr.mu.Lock()
+	defer kr.mu.Unlock()
+	kr.quotas = quotas
+}
+
+// CurrentKey returns the key to use next. With a quota source it is the
+// key SelectKey picks, or an empty string when every key is exhausted.
 func (kr *KeyRotator) CurrentKey() string {
+	kr.mu.RLock()
+	quotas := kr.quotas
+	kr.mu.RUnlock()
+
+	if quotas != nil {
+		key, _ := kr.SelectKey("")
+		return key
+	}
+
 	kr.mu.RLock()
 	defer kr.mu.RUnlock()
 
@@ -42,6 +97,94 @@ func (kr *KeyRotator) CurrentKey() string {
 	return kr.keys[kr.currentIndex]
 }
 
+// SelectKey returns preferred if it still has quota left and is ready
+// within one token interval, as it is when only paced, and otherwise the
+// key with quota left that is ready soonest, preferring the one with
+// the most daily requests left. Exhausted and quarantined keys are
+// skipped; when all of them are exhausted, an *ExhaustedError says when
+// the earliest one resets, and when all are quarantined the error is
+// ErrAllQuarantined. Without a quota source, SelectKey returns preferred
+// or the current key, or the next key that is not quarantined.
+func (kr *KeyRotator) SelectKey(preferred string) (string, error) {
+	kr.mu.RLock()
+	quotas := kr.quotas
+	var keys []string
+	for _, key := range kr.keys {
+		if !kr.quarantined(key) {
+			keys = append(keys, key)
+		}
+	}
+	if kr.quarantined(preferred) {
+		preferred = ""
+	}
+	current := ""
+	if len(kr.keys) > 0 && !kr.quarantined(kr.keys[kr.currentIndex]) {
+		current = kr.keys[kr.currentIndex]
+	}
+	total := len(kr.keys)
+	kr.mu.RUnlock()
+
+	if total == 0 {
+		return "", fmt.Errorf("no API key available")
+	}
+	if len(keys) == 0 {
+		return "", ErrAllQuarantined
+	}
+	if quotas == nil {
+		if preferred != "" {
+			return preferred, nil
+		}
+		if current != "" {
+			return current, nil
+		}
+		return keys[0], nil
+	}
+
+	now := time.Now()
+
+	// A preferred key that was throttled by the API, or is waiting for its
+	// quota to reset, is passed over for a key that is ready sooner
+	if preferred != "" {
+		daily, monthly := quotas.Remaining(preferred)
+		ready := quotas.NextAvailable(preferred).Sub(now) <= max(quotas.Interval(preferred), time.Second)
+		if daily > 0 && monthly > 0 && ready {
+			return preferred, nil
+		}
+	}
+
+	var best string
+	var bestAt, resetAt time.Time
+	bestDaily := 0
+
+	for _, key := range keys {
+		next := quotas.NextAvailable(key)
+		daily, monthly := quotas.Remaining(key)
+
+		// Keys ready within a second count as ready now, so that among
+		// them the one with the most quota left wins
+		if next.Sub(now) < time.Second {
+			next = now
+		}
+
+		if daily == 0 || monthly == 0 {
+			if resetAt.IsZero() || next.Before(resetAt) {
+				resetAt = next
+			}
+			continue
+		}
+
+		if best == "" || next.Before(bestAt) || (next.Equal(bestAt) && daily > bestDaily) {
+			best, bestAt, bestDaily = key, next, daily
+		}
+	}
+
+	if best == "" {
+		return "", &ExhaustedError{ResetAt: resetAt}
+	}
+
+	return best, nil
+}
+
 func (kr *KeyRotator) RotateKey() string {
 	kr.mu.Lock()
 	defer kr.mu.Unlock()
@@ -56,6 +199,13 @@ func (kr *KeyRotator) RotateKey() string {
 	return kr.keys[kr.currentIndex]
 }
 
+// Keys returns a copy of all keys, in the order they were given.
+func (kr *KeyRotator) Keys() []string {
+	kr.mu.RLock()
+	defer kr.mu.RUnlock()
+	return append([]string(nil), kr.keys...)
+}
+
 func (kr *KeyRotator) GetKeyCount() int {
 	kr.mu.RLock()
 	defer kr.mu.RUnlock()
@@ -73,10 +223,6 @@ func (kr *KeyRotator) Stop() {
 }
 
 func (kr *KeyRotator) autoRotate() {
-	kr.mu.Lock()
-	kr.started = true
-	kr.mu.Unlock()
-
 	ticker := time.NewTicker(kr.rotationInterval)
 	defer ticker.Stop()
 
@@ -65,4 +65,78 @@ func TestKeyRotator_AutoRotation(t *testing.T) {
 	}
 
 	rotator.Stop()
-}
\ No newline at end of file
+}
+
+// fakeQuotas is a QuotaSource with fixed readiness and remaining quota.
+// Every key earns a token every 15 seconds, as a public key does.
+type fakeQuotas struct {
+	next      map[string]time.Time
+	remaining map[string]int
+}
+
+func (f *fakeQuotas) NextAvailable(apiKey string) time.Time {
+	if next, ok := f.next[apiKey]; ok {
+		return next
+	}
+	return time.Now()
+}
+
+func (f *fakeQuotas) Interval(apiKey string) time.Duration {
+	return 15 * time.Second
+}
+
+func (f *fakeQuotas) Remaining(apiKey string) (daily, monthly int) {
+	return f.remaining[apiKey], 1000
+}
+
+func TestKeyRotator_SelectKey(t *testing.T) {
+	rotator := NewKeyRotator([]string{"key1", "key2", "key3"}, time.Second)
+	quotas := &fakeQuotas{
+		next:      map[string]time.Time{"key2": time.Now().Add(10 * time.Second)},
+		remaining: map[string]int{"key1": 0, "key2": 400, "key3": 10},
+	}
+	rotator.SetQuotaSource(quotas)
+
+	// key2 is only paced, ready within one token interval
+	if key, err := rotator.SelectKey("key2"); err != nil || key != "key2" {
+		t.Errorf("Expected preferred key with quota left, got %s, %v", key, err)
+	}
+
+	// key1 is exhausted and key2 is not ready yet
+	if key, err := rotator.SelectKey("key1"); err != nil || key != "key3" {
+		t.Errorf("Expected key3 instead of exhausted key1, got %s, %v", key, err)
+	}
+
+	// key2 is throttled well beyond its interval, so a ready key wins
+	quotas.next = map[string]time.Time{"key2": time.Now().Add(time.Hour)}
+	if key, err := rotator.SelectKey("key2"); err != nil || key != "key3" {
+		t.Errorf("Expected ready key3 instead of throttled key2, got %s, %v", key, err)
+	}
+
+	quotas.next = nil
+	if key := rotator.CurrentKey(); key != "key2" {
+		t.Errorf("Expected ready key with most quota left, got %s", key)
+	}
+}
+
+func TestKeyRotator_SelectKey_AllExhausted(t *testing.T) {
+	rotator := NewKeyRotator([]string{"key1", "key2"}, time.Second)
+	reset := time.Now().Add(3 * time.Hour)
+	rotator.SetQuotaSource(&fakeQuotas{
+		next:      map[string]time.Time{"key1": time.Now().Add(5 * time.Hour), "key2": reset},
+		remaining: map[string]int{},
+	})
+
+	_, err := rotator.SelectKey("key1")
+
+	exhausted, ok := err.(*ExhaustedError)
+	if !ok {
+		t.Fatalf("Expected ExhaustedError, got %v", err)
+	}
+	if !exhausted.ResetAt.Equal(reset) {
+		t.Errorf("Expected earliest reset %v, got %v", reset, exhausted.ResetAt)
+	}
+	if rotator.CurrentKey() != "" {
+		t.Error("Expected no current key when all keys are exhausted")
+	}
+}
@@ -1,8 +1,8 @@
 package config
 
 import (
-	"bufio"
 	"fmt"
+	"io"
 	"net/url"
 	"os"
 	"strings"
@@ -11,29 +11,59 @@ import (
 	"github.com/pluckware/tyvt/pkg/validation"
 )
 
+// Diagnostics receives the validation warnings and notices Load prints.
+// Set it to io.Discard to silence them.
+var Diagnostics io.Writer = os.Stderr
+
 type Config struct {
 	Domains          []string      `json:"domains"`
 	APIKeys          []string      `json:"api_keys"`
 	OutputFile       string        `json:"output_file,omitempty"`
 	ProxyURL         *url.URL      `json:"-"` // Optional proxy URL (not serialized to JSON)
 	RotationInterval time.Duration `json:"rotation_interval"`
+
+	// Recursive expansion of discovered subdomains (see Scanner.Run).
+	// MaxDepth limits how many levels below the input domains are scanned
+	// and Scope, when set, restricts expansion to these domain suffixes.
+	Recursive bool     `json:"recursive,omitempty"`
+	MaxDepth  int      `json:"max_depth,omitempty"`
+	Scope     []string `json:"scope,omitempty"`
+
+	// Quota profile of each API key, resolved by ApplyQuotas once the
+	// quota file is known. keyProfiles holds the unresolved profile each
+	// key selected in the keys file.
+	KeyQuotas    map[string]QuotaProfile `json:"-"`
+	DefaultQuota QuotaProfile            `json:"-"`
+	keyProfiles  map[string]string
 }
 
 // Load reads configuration from files and validates all inputs.
-// proxyURL is optional - pass empty string for no proxy.
+// proxyURL is optional - pass empty string for no proxy. A domainsFile of
+// Stdin reads the domains from standard input.
 func Load(domainsFile, keysFile, outputFile, proxyURL string) (*Config, error) {
-	domains, err := readLines(domainsFile)
+	return LoadInput(DomainInput{Files: []string{domainsFile}, Stdin: os.Stdin}, keysFile, outputFile, proxyURL)
+}
+
+// LoadInput is Load with the domains read from every source of input. The
+// domains of all sources are validated together and duplicates removed.
+func LoadInput(input DomainInput, keysFile, outputFile, proxyURL string) (*Config, error) {
+	domains, err := input.read()
 	if err != nil {
 		return nil, fmt.Errorf("failed to read domains file: %w", err)
 	}
 
-	apiKeys, err := readLines(keysFile)
+	keyLines, err := readLines(keysFile)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read API keys file: %w", err)
 	}
 
+	apiKeys, keyProfiles, err := parseKeyLines(keyLines)
+	if err != nil {
+		return nil, fmt.Errorf("invalid API keys file: %w", err)
+	}
+
 	if len(domains) == 0 {
-		return nil, fmt.Errorf("no domains found in %s", domainsFile)
+		return nil, fmt.Errorf("no domains found in %s", input)
 	}
 
 	if len(apiKeys) == 0 {
@@ -44,12 +74,16 @@ func Load(domainsFile, keysFile, outputFile, proxyURL string) (*Config, error) {
 	domains = filterEmptyStrings(domains)
 	apiKeys = filterEmptyStrings(apiKeys)
 
+	// Extract the domain names from URLs, wildcards and email addresses
+	domains, normalized := validation.NormalizeDomains(domains)
+	reportNormalized(normalized)
+
 	// Validate domains
 	validDomains, domainErrors := validation.ValidateDomains(domains)
 	if len(domainErrors) > 0 {
-		fmt.Fprintf(os.Stderr, "⚠️  Warning: Found %d invalid domain(s):\n", len(domainErrors))
+		fmt.Fprintf(Diagnostics, "⚠️  Warning: Found %d invalid domain(s):\n", len(domainErrors))
 		for _, err := range domainErrors {
-			fmt.Fprintf(os.Stderr, "   - %v\n", err)
+			fmt.Fprintf(Diagnostics, "   - %v\n", err)
 		}
 	}
 
@@ -57,12 +91,17 @@ func Load(domainsFile, keysFile, outputFile, proxyURL string) (*Config, error) {
 		return nil, fmt.Errorf("no valid domains found after validation")
 	}
 
+	uniqueDomains := validation.DedupeDomains(validDomains)
+	if duplicates := len(validDomains) - len(uniqueDomains); duplicates > 0 {
+		fmt.Fprintf(Diagnostics, "✓ Removed %d duplicate domain(s)\n", duplicates)
+	}
+
 	// Validate API keys
 	validKeys, keyErrors := validation.ValidateAPIKeys(apiKeys)
 	if len(keyErrors) > 0 {
-		fmt.Fprintf(os.Stderr, "⚠️  Warning: Found %d invalid API key(s):\n", len(keyErrors))
+		fmt.Fprintf(Diagnostics, "⚠️  Warning: Found %d invalid API key(s):\n", len(keyErrors))
 		for _, err := range keyErrors {
-			fmt.Fprintf(os.Stderr, "   - %v\n", err)
+			fmt.Fprintf(Diagnostics, "   - %v\n", err)
 		}
 	}
 
@@ -72,7 +111,7 @@ func Load(domainsFile, keysFile, outputFile, proxyURL string) (*Config, error) {
 
 	// Log validation summary
 	if len(validDomains) < len(domains) || len(validKeys) < len(apiKeys) {
-		fmt.Fprintf(os.Stderr, "✓ Validation complete: %d/%d domains valid, %d/%d API keys valid\n\n", 
+		fmt.Fprintf(Diagnostics, "✓ Validation complete: %d/%d domains valid, %d/%d API keys valid\n\n", 
 			len(validDomains), len(domains), len(validKeys), len(apiKeys))
 	}
 
@@ -83,37 +122,99 @@ func Load(domainsFile, keysFile, outputFile, proxyURL string) (*Config, error) {
 		if err != nil {
 			return nil, fmt.Errorf("invalid proxy URL: %w", err)
 		}
-		fmt.Fprintf(os.Stderr, "✓ Using proxy: %s://%s\n\n", parsedProxyURL.Scheme, parsedProxyURL.Host)
+		fmt.Fprintf(Diagnostics, "✓ Using proxy: %s://%s\n\n", parsedProxyURL.Scheme, parsedProxyURL.Host)
 	}
 
 	return &Config{
-		Domains:          validDomains,
+		Domains:          uniqueDomains,
 		APIKeys:          validKeys,
 		OutputFile:       outputFile,
 		ProxyURL:         parsedProxyURL,
 		RotationInterval: 15 * time.Second,
+		keyProfiles:      keyProfiles,
 	}, nil
 }
 
-func readLines(filename string) ([]string, error) {
-	file, err := os.Open(filename)
+// reportNormalized prints every input that was not a plain domain and how
+// it was transformed.
+func reportNormalized(normalized []validation.Normalization) {
+	if len(normalized) == 0 {
+		return
+	}
+
+	fmt.Fprintf(Diagnostics, "✓ Normalized %d domain input(s):\n", len(normalized))
+	for _, n := range normalized {
+		fmt.Fprintf(Diagnostics, "   - %s -> %s (%s)\n", n.Input, n.Domain, strings.Join(n.Changes, ", "))
+	}
+}
+
+// LoadKeys reads the API keys of a keys file without validating them, for
+// commands that report on every key such as keys check and quota. The
+// returned Config only holds the keys and the quota profiles they select.
+func LoadKeys(keysFile string) (*Config, error) {
+	lines, err := readLines(keysFile)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("failed to read API keys file: %w", err)
+	}
+
+	keys, keyProfiles, err := parseKeyLines(lines)
+	if err != nil {
+		return nil, fmt.Errorf("invalid API keys file: %w", err)
 	}
-	defer file.Close()
 
-	var lines []string
-	scanner := bufio.NewScanner(file)
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("no API keys found in %s", keysFile)
+	}
+
+	return &Config{APIKeys: keys, keyProfiles: keyProfiles}, nil
+}
 
-	for scanner.Scan() {
-		line := strings.TrimSpace(scanner.Text())
-		// Skip empty lines and comments
-		if line != "" && !strings.HasPrefix(line, "#") {
-			lines = append(lines, line)
+// parseKeyLines splits each keys file line into the API key and the quota
+// profile it optionally selects, separated by whitespace:
+//
+//	<api key> [profile name | per-minute/daily/monthly]
+func parseKeyLines(lines []string) ([]string, map[string]string, error) {
+	keys := make([]string, 0, len(lines))
+	profiles := make(map[string]string)
+
+	for _, line := range lines {
+		fields := strings.Fields(line)
+		switch len(fields) {
+		case 1:
+		case 2:
+			profiles[fields[0]] = fields[1]
+		default:
+			return nil, nil, fmt.Errorf("expected an API key and an optional quota profile, got %d fields", len(fields))
 		}
+		keys = append(keys, fields[0])
 	}
 
-	return lines, scanner.Err()
+	return keys, profiles, nil
+}
+
+// ParseScope splits a comma separated list of scope domains, normalizing
+// and validating each one, so that entries such as *.example.com can be
+// copied from a program's scope. An empty list means no explicit scope.
+func ParseScope(list string) ([]string, error) {
+	var scope []string
+	var normalized []validation.Normalization
+	for _, entry := range strings.Split(list, ",") {
+		if strings.TrimSpace(entry) == "" {
+			continue
+		}
+
+		domain, changes := validation.NormalizeDomain(entry)
+		if len(changes) > 0 {
+			normalized = append(normalized, validation.Normalization{Input: strings.TrimSpace(entry), Domain: domain, Changes: changes})
+		}
+		domain = strings.ToLower(domain)
+		if err := validation.ValidateDomain(domain); err != nil {
+			return nil, fmt.Errorf("invalid scope domain: %w", err)
+		}
+		scope = append(scope, domain)
+	}
+	reportNormalized(normalized)
+	return scope, nil
 }
 
 func filterEmptyStrings(slice []string) []string {
@@ -207,4 +207,30 @@ func createTempFile(t *testing.T, name, content string) string {
 	}
 
 	return file.Name()
-}
\ No newline at end of file
+}
+
+func TestParseScope(t *testing.T) {
+	scope, err := ParseScope(" Example.com, ,example.org")
+	if err != nil {
+		t.Fatalf("ParseScope failed: %v", err)
+	}
+	if len(scope) != 2 || scope[0] != "example.com" || scope[1] != "example.org" {
+		t.Errorf("Unexpected scope: %v", scope)
+	}
+
+	if scope, err := ParseScope(""); err != nil || scope != nil {
+		t.Errorf("Expected empty scope, got %v, %v", scope, err)
+	}
+
+	if _, err := ParseScope("example.com,not_a_domain"); err == nil {
+		t.Error("Expected error for invalid scope domain")
+	}
+
+	scope, err = ParseScope("*.Example.com,https://example.org/")
+	if err != nil {
+		t.Fatalf("ParseScope failed: %v", err)
+	}
+	if len(scope) != 2 || scope[0] != "example.com" || scope[1] != "example.org" {
+		t.Errorf("Expected normalized scope, got %v", scope)
+	}
+}
@@ -0,0 +1,108 @@
+package config
+
+import (
+	"bufio"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// Stdin is the domains file name that reads from standard input.
+const Stdin = "-"
+
+// DomainInput lists where the domains to scan come from. Files are read in
+// order, with Stdin reading from the Stdin reader, followed by the Domains
+// given directly. Gzip compressed input is detected and decompressed.
+type DomainInput struct {
+	Files   []string
+	Domains []string
+	Stdin   io.Reader
+}
+
+// String describes the sources for messages, such as "a.txt, stdin".
+func (in DomainInput) String() string {
+	var sources []string
+	for _, name := range in.Files {
+		if name == Stdin {
+			name = "stdin"
+		}
+		sources = append(sources, name)
+	}
+	if len(in.Domains) > 0 {
+		sources = append(sources, "-domain")
+	}
+	return strings.Join(sources, ", ")
+}
+
+// read returns the domain lines of every source, in order.
+func (in DomainInput) read() ([]string, error) {
+	var domains []string
+
+	for _, name := range in.Files {
+		var lines []string
+		var err error
+		if name == Stdin {
+			if in.Stdin == nil {
+				return nil, fmt.Errorf("no standard input to read domains from")
+			}
+			lines, err = scanLines(in.Stdin)
+		} else {
+			lines, err = readLines(name)
+		}
+		if err != nil {
+			return nil, err
+		}
+		domains = append(domains, lines...)
+	}
+
+	for _, domain := range in.Domains {
+		domains = append(domains, strings.TrimSpace(domain))
+	}
+
+	return domains, nil
+}
+
+// gzipMagic starts every gzip stream.
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// scanLines returns the lines of r that are neither empty nor comments,
+// decompressing r first if it is gzip compressed.
+func scanLines(r io.Reader) ([]string, error) {
+	buffered := bufio.NewReader(r)
+	if magic, err := buffered.Peek(len(gzipMagic)); err == nil && string(magic) == string(gzipMagic) {
+		gz, err := gzip.NewReader(buffered)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decompress input: %w", err)
+		}
+		defer gz.Close()
+		r = gz
+	} else {
+		r = buffered
+	}
+
+	var lines []string
+	scanner := bufio.NewScanner(r)
+
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		// Skip empty lines and comments
+		if line != "" && !strings.HasPrefix(line, "#") {
+			lines = append(lines, line)
+		}
+	}
+
+	return lines, scanner.Err()
+}
+
+// readLines returns the lines of a file as scanLines does.
+func readLines(filename string) ([]string, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return scanLines(file)
+}
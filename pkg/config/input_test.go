@@ -0,0 +1,107 @@
+package config
+
+import (
+	"bytes"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const inputTestKey = "c9a9cfea8329cdf114760ed36fc8468dd1a1cb826d4adab9fee96bad9ec74add"
+
+func TestLoadInput_MixesSources(t *testing.T) {
+	dir := t.TempDir()
+	keysFile := filepath.Join(dir, "keys.txt")
+	os.WriteFile(keysFile, []byte(inputTestKey), 0644)
+
+	plainFile := filepath.Join(dir, "domains.txt")
+	os.WriteFile(plainFile, []byte("example.com\n# comment\nwww.example.com\n"), 0644)
+
+	var compressed bytes.Buffer
+	gz := gzip.NewWriter(&compressed)
+	gz.Write([]byte("mail.example.com\nEXAMPLE.COM\n"))
+	gz.Close()
+	gzipFile := filepath.Join(dir, "domains.txt.gz")
+	os.WriteFile(gzipFile, compressed.Bytes(), 0644)
+
+	input := DomainInput{
+		Files:   []string{plainFile, Stdin, gzipFile},
+		Domains: []string{"api.example.com", "www.example.com"},
+		Stdin:   strings.NewReader("invalid_domain\nshop.example.com\n"),
+	}
+
+	cfg, err := LoadInput(input, keysFile, "", "")
+	if err != nil {
+		t.Fatalf("LoadInput failed: %v", err)
+	}
+
+	expected := []string{"example.com", "www.example.com", "shop.example.com", "mail.example.com", "api.example.com"}
+	if strings.Join(cfg.Domains, ",") != strings.Join(expected, ",") {
+		t.Errorf("Expected %v, got %v", expected, cfg.Domains)
+	}
+}
+
+func TestLoadInput_GzipFromStdin(t *testing.T) {
+	keysFile := filepath.Join(t.TempDir(), "keys.txt")
+	os.WriteFile(keysFile, []byte(inputTestKey), 0644)
+
+	var compressed bytes.Buffer
+	gz := gzip.NewWriter(&compressed)
+	gz.Write([]byte("example.com\n"))
+	gz.Close()
+
+	cfg, err := LoadInput(DomainInput{Files: []string{Stdin}, Stdin: &compressed}, keysFile, "", "")
+	if err != nil {
+		t.Fatalf("LoadInput failed: %v", err)
+	}
+	if len(cfg.Domains) != 1 || cfg.Domains[0] != "example.com" {
+		t.Errorf("Expected example.com, got %v", cfg.Domains)
+	}
+}
+
+func TestLoadInput_NoDomains(t *testing.T) {
+	keysFile := filepath.Join(t.TempDir(), "keys.txt")
+	os.WriteFile(keysFile, []byte(inputTestKey), 0644)
+
+	_, err := LoadInput(DomainInput{Files: []string{Stdin}, Stdin: strings.NewReader("")}, keysFile, "", "")
+	if err == nil || !strings.Contains(err.Error(), "no domains found in stdin") {
+		t.Errorf("Expected 'no domains found in stdin' error, got %v", err)
+	}
+}
+
+func TestLoadInput_NormalizesDomains(t *testing.T) {
+	keysFile := filepath.Join(t.TempDir(), "keys.txt")
+	os.WriteFile(keysFile, []byte(inputTestKey), 0644)
+
+	input := DomainInput{
+		Files: []string{Stdin},
+		Stdin: strings.NewReader("https://example.com/path\n*.example.com\nadmin@mail.example.com\napi.example.com:8443\n"),
+	}
+
+	var diagnostics bytes.Buffer
+	Diagnostics = &diagnostics
+	defer func() { Diagnostics = os.Stderr }()
+
+	cfg, err := LoadInput(input, keysFile, "", "")
+	if err != nil {
+		t.Fatalf("LoadInput failed: %v", err)
+	}
+
+	expected := []string{"example.com", "mail.example.com", "api.example.com"}
+	if strings.Join(cfg.Domains, ",") != strings.Join(expected, ",") {
+		t.Errorf("Expected %v, got %v", expected, cfg.Domains)
+	}
+
+	for _, report := range []string{
+		"https://example.com/path -> example.com (extracted host from URL)",
+		"*.example.com -> example.com (removed wildcard)",
+		"admin@mail.example.com -> mail.example.com (extracted domain from email address)",
+		"api.example.com:8443 -> api.example.com (removed port)",
+	} {
+		if !strings.Contains(diagnostics.String(), report) {
+			t.Errorf("Expected %q to be reported, got:\n%s", report, diagnostics.String())
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/pluckware/tyvt/pkg/validation"
+)
+
+// QuotaProfile is the allowance of an API key: how many requests it may
+// make per minute, per day and per month.
+type QuotaProfile struct {
+	PerMinute int `json:"per_minute"`
+	Daily     int `json:"daily"`
+	Monthly   int `json:"monthly"`
+}
+
+// PublicProfile is the allowance of a free VirusTotal public API key.
+var PublicProfile = QuotaProfile{PerMinute: 4, Daily: 500, Monthly: 15500}
+
+// QuotaFile is the quota configuration file. Profiles defines named
+// profiles that keys can refer to in the keys file, and Default names the
+// profile of keys that do not pick one.
+type QuotaFile struct {
+	Default  string                  `json:"default,omitempty"`
+	Profiles map[string]QuotaProfile `json:"profiles"`
+}
+
+// LoadQuotaFile reads and validates a quota configuration file.
+func LoadQuotaFile(path string) (*QuotaFile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read quota file: %w", err)
+	}
+
+	var file QuotaFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to decode quota file %s: %w", path, err)
+	}
+
+	for name, profile := range file.Profiles {
+		if err := profile.validate(); err != nil {
+			return nil, fmt.Errorf("quota profile %q: %w", name, err)
+		}
+	}
+
+	return &file, nil
+}
+
+func (p QuotaProfile) validate() error {
+	if p.PerMinute <= 0 || p.Daily <= 0 || p.Monthly <= 0 {
+		return fmt.Errorf("per-minute, daily and monthly limits must be positive")
+	}
+	return nil
+}
+
+// ParseQuotaProfile resolves the profile a key selects in the keys file:
+// either the name of a profile, or inline limits written as
+// per-minute/daily/monthly, such as 4/500/15500. The built-in "public"
+// profile is always available.
+func ParseQuotaProfile(spec string, profiles map[string]QuotaProfile) (QuotaProfile, error) {
+	if profile, ok := profiles[spec]; ok {
+		return profile, nil
+	}
+	if spec == "public" {
+		return PublicProfile, nil
+	}
+
+	parts := strings.Split(spec, "/")
+	if len(parts) != 3 {
+		return QuotaProfile{}, fmt.Errorf("unknown quota profile '%s' (use a profile name or per-minute/daily/monthly)", spec)
+	}
+
+	var limits [3]int
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil {
+			return QuotaProfile{}, fmt.Errorf("invalid quota '%s': %w", spec, err)
+		}
+		limits[i] = n
+	}
+
+	profile := QuotaProfile{PerMinute: limits[0], Daily: limits[1], Monthly: limits[2]}
+	if err := profile.validate(); err != nil {
+		return QuotaProfile{}, fmt.Errorf("invalid quota '%s': %w", spec, err)
+	}
+
+	return profile, nil
+}
+
+// ApplyQuotas resolves the quota profile of every key from the profile
+// names or inline limits in the keys file and the optional quota file.
+// Keys without a profile get the quota file's default, or PublicProfile.
+func (c *Config) ApplyQuotas(file *QuotaFile) error {
+	var profiles map[string]QuotaProfile
+	c.DefaultQuota = PublicProfile
+
+	if file != nil {
+		profiles = file.Profiles
+		if file.Default != "" {
+			profile, err := ParseQuotaProfile(file.Default, profiles)
+			if err != nil {
+				return fmt.Errorf("default quota: %w", err)
+			}
+			c.DefaultQuota = profile
+		}
+	}
+
+	c.KeyQuotas = make(map[string]QuotaProfile)
+	for key, spec := range c.keyProfiles {
+		profile, err := ParseQuotaProfile(spec, profiles)
+		if err != nil {
+			return fmt.Errorf("key ending in %s: %w", validation.MaskAPIKey(key), err)
+		}
+		c.KeyQuotas[key] = profile
+	}
+
+	return nil
+}
+
+// QuotaFor returns the quota profile of an API key.
+func (c *Config) QuotaFor(apiKey string) QuotaProfile {
+	if profile, ok := c.KeyQuotas[apiKey]; ok {
+		return profile
+	}
+	if c.DefaultQuota == (QuotaProfile{}) {
+		return PublicProfile
+	}
+	return c.DefaultQuota
+}
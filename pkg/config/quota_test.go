@@ -0,0 +1,104 @@
+package config
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+const (
+	publicKey  = "c9a9cfea8329cdf114760ed36fc8468dd1a1cb826d4adab9fee96bad9ec74add"
+	premiumKey = "3b3febd37b5f774837bdb9fa4d6cfc78d022ab2f35b1bba18b152d779a77cbb9"
+	inlineKey  = "ABCDEF0123456789ABCDEF0123456789ABCDEF0123456789ABCDEF0123456789"
+)
+
+func TestApplyQuotas(t *testing.T) {
+	domainsFile := createTempFile(t, "domains.txt", "example.com")
+	keysFile := createTempFile(t, "keys.txt", publicKey+"\n"+premiumKey+" premium\n"+inlineKey+"\t30/20000/600000")
+	quotaFile := createTempFile(t, "quotas.json", `{"profiles":{"premium":{"per_minute":1000,"daily":100000,"monthly":3000000}}}`)
+
+	defer os.Remove(domainsFile)
+	defer os.Remove(keysFile)
+	defer os.Remove(quotaFile)
+
+	cfg, err := Load(domainsFile, keysFile, "", "")
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if len(cfg.APIKeys) != 3 || cfg.APIKeys[1] != premiumKey {
+		t.Fatalf("Expected keys without their profiles, got %v", cfg.APIKeys)
+	}
+
+	quotas, err := LoadQuotaFile(quotaFile)
+	if err != nil {
+		t.Fatalf("LoadQuotaFile failed: %v", err)
+	}
+	if err := cfg.ApplyQuotas(quotas); err != nil {
+		t.Fatalf("ApplyQuotas failed: %v", err)
+	}
+
+	tests := []struct {
+		key  string
+		want QuotaProfile
+	}{
+		{publicKey, PublicProfile},
+		{premiumKey, QuotaProfile{PerMinute: 1000, Daily: 100000, Monthly: 3000000}},
+		{inlineKey, QuotaProfile{PerMinute: 30, Daily: 20000, Monthly: 600000}},
+	}
+	for _, tt := range tests {
+		if got := cfg.QuotaFor(tt.key); got != tt.want {
+			t.Errorf("QuotaFor(%s...) = %+v, want %+v", tt.key[:8], got, tt.want)
+		}
+	}
+}
+
+func TestApplyQuotas_UnknownProfile(t *testing.T) {
+	domainsFile := createTempFile(t, "domains.txt", "example.com")
+	keysFile := createTempFile(t, "keys.txt", premiumKey+" premium")
+
+	defer os.Remove(domainsFile)
+	defer os.Remove(keysFile)
+
+	cfg, err := Load(domainsFile, keysFile, "", "")
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	if err := cfg.ApplyQuotas(nil); err == nil {
+		t.Error("Expected error for profile missing from the quota file")
+	}
+
+	// Keys are not validated yet, so a key too short to mask must not panic
+	shortKeysFile := createTempFile(t, "short_keys.txt", "abc bogus")
+	defer os.Remove(shortKeysFile)
+
+	cfg, err = LoadKeys(shortKeysFile)
+	if err != nil {
+		t.Fatalf("LoadKeys failed: %v", err)
+	}
+
+	if err := cfg.ApplyQuotas(nil); err == nil || !strings.Contains(err.Error(), "key ending in ****") {
+		t.Errorf("Expected masked unknown profile error for short key, got %v", err)
+	}
+}
+
+func TestParseQuotaProfile(t *testing.T) {
+	tests := []struct {
+		spec    string
+		wantErr bool
+	}{
+		{"public", false},
+		{"4/500/15500", false},
+		{"4/500", true},
+		{"0/500/15500", true},
+		{"four/500/15500", true},
+		{"gold", true},
+	}
+
+	for _, tt := range tests {
+		_, err := ParseQuotaProfile(tt.spec, nil)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseQuotaProfile(%q) error = %v, wantErr %v", tt.spec, err, tt.wantErr)
+		}
+	}
+}
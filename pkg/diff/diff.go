@@ -0,0 +1,91 @@
+package diff
+
+import (
+	"strings"
+
+	"github.com/pluckware/tyvt/internal/client"
+)
+
+// DomainDiff is the change in a domain's report relative to a baseline.
+// Added and Removed reuse the DomainResult model and only carry the URLs,
+// subdomains and resolutions that appeared or disappeared.
+type DomainDiff struct {
+	Domain  string               `json:"domain"`
+	Added   *client.DomainResult `json:"added,omitempty"`
+	Removed *client.DomainResult `json:"removed,omitempty"`
+}
+
+// Empty reports whether nothing was added or removed.
+func (d *DomainDiff) Empty() bool {
+	return d.Added == nil && d.Removed == nil
+}
+
+// Compare returns the difference between a domain's baseline result and its
+// current result. A nil baseline means the domain is new, so everything in
+// current counts as added.
+func Compare(baseline, current *client.DomainResult) *DomainDiff {
+	if baseline == nil {
+		baseline = &client.DomainResult{Domain: current.Domain}
+	}
+
+	added := &client.DomainResult{
+		Domain:       current.Domain,
+		ResponseCode: current.ResponseCode,
+		Timestamp:    current.Timestamp,
+	}
+	removed := &client.DomainResult{
+		Domain:       current.Domain,
+		ResponseCode: baseline.ResponseCode,
+		Timestamp:    baseline.Timestamp,
+	}
+
+	added.UndetectedURLs, removed.UndetectedURLs = compareLists(baseline.UndetectedURLs, current.UndetectedURLs,
+		func(u client.UndetectedURL) string { return u.URL })
+	added.DetectedURLs, removed.DetectedURLs = compareLists(baseline.DetectedURLs, current.DetectedURLs,
+		func(u client.DetectedURL) string { return u.URL })
+	added.Subdomains, removed.Subdomains = compareLists(baseline.Subdomains, current.Subdomains,
+		func(s string) string { return strings.ToLower(s) })
+	added.Resolutions, removed.Resolutions = compareLists(baseline.Resolutions, current.Resolutions,
+		func(r client.Resolution) string { return r.IPAddress })
+
+	d := &DomainDiff{Domain: current.Domain}
+	if hasChanges(added) {
+		d.Added = added
+	}
+	if hasChanges(removed) {
+		d.Removed = removed
+	}
+
+	return d
+}
+
+// compareLists returns the items of current whose key is missing from
+// baseline, and the items of baseline whose key is missing from current.
+func compareLists[T any](baseline, current []T, key func(T) string) (added, removed []T) {
+	inBaseline := make(map[string]bool, len(baseline))
+	for _, item := range baseline {
+		inBaseline[key(item)] = true
+	}
+
+	inCurrent := make(map[string]bool, len(current))
+	for _, item := range current {
+		k := key(item)
+		inCurrent[k] = true
+		if !inBaseline[k] {
+			added = append(added, item)
+		}
+	}
+
+	for _, item := range baseline {
+		if !inCurrent[key(item)] {
+			removed = append(removed, item)
+		}
+	}
+
+	return added, removed
+}
+
+func hasChanges(r *client.DomainResult) bool {
+	return len(r.UndetectedURLs) > 0 || len(r.DetectedURLs) > 0 ||
+		len(r.Subdomains) > 0 || len(r.Resolutions) > 0
+}
@@ -0,0 +1,67 @@
+package diff
+
+import (
+	"testing"
+
+	"github.com/pluckware/tyvt/internal/client"
+)
+
+func TestCompare(t *testing.T) {
+	baseline := &client.DomainResult{
+		Domain:         "example.com",
+		ResponseCode:   1,
+		UndetectedURLs: []client.UndetectedURL{{URL: "http://example.com/a"}, {URL: "http://example.com/old"}},
+		Subdomains:     []string{"WWW.example.com"},
+		Resolutions:    []client.Resolution{{IPAddress: "192.0.2.1"}},
+	}
+	current := &client.DomainResult{
+		Domain:         "example.com",
+		ResponseCode:   1,
+		UndetectedURLs: []client.UndetectedURL{{URL: "http://example.com/a"}, {URL: "http://example.com/new"}},
+		Subdomains:     []string{"www.example.com", "api.example.com"},
+		Resolutions:    []client.Resolution{{IPAddress: "192.0.2.1"}},
+	}
+
+	d := Compare(baseline, current)
+
+	if d.Added == nil || len(d.Added.UndetectedURLs) != 1 || d.Added.UndetectedURLs[0].URL != "http://example.com/new" {
+		t.Errorf("Expected only the new URL to be added, got %+v", d.Added)
+	}
+	if len(d.Added.Subdomains) != 1 || d.Added.Subdomains[0] != "api.example.com" {
+		t.Errorf("Expected subdomains to be compared case-insensitively, got %v", d.Added.Subdomains)
+	}
+	if len(d.Added.Resolutions) != 0 {
+		t.Errorf("Expected no added resolutions, got %v", d.Added.Resolutions)
+	}
+	if d.Removed == nil || len(d.Removed.UndetectedURLs) != 1 || d.Removed.UndetectedURLs[0].URL != "http://example.com/old" {
+		t.Errorf("Expected the old URL to be removed, got %+v", d.Removed)
+	}
+}
+
+func TestCompare_NewDomain(t *testing.T) {
+	current := &client.DomainResult{
+		Domain:       "example.com",
+		ResponseCode: 1,
+		DetectedURLs: []client.DetectedURL{{URL: "http://example.com/bad", Positives: 2}},
+	}
+
+	d := Compare(nil, current)
+	if d.Added == nil || len(d.Added.DetectedURLs) != 1 {
+		t.Errorf("Expected everything to be added for a new domain, got %+v", d.Added)
+	}
+	if d.Removed != nil {
+		t.Errorf("Expected nothing removed, got %+v", d.Removed)
+	}
+}
+
+func TestCompare_Unchanged(t *testing.T) {
+	result := &client.DomainResult{
+		Domain:         "example.com",
+		ResponseCode:   1,
+		UndetectedURLs: []client.UndetectedURL{{URL: "http://example.com/a"}},
+	}
+
+	if d := Compare(result, result); !d.Empty() {
+		t.Errorf("Expected empty diff, got %+v", d)
+	}
+}
@@ -0,0 +1,91 @@
+package files
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/pluckware/tyvt/internal/client"
+)
+
+// LoadBaseline reads an earlier jsonl result set and returns the result of
+// each domain, keyed by lower-cased domain. Both jsonl modes are accepted:
+// per-domain lines are used as they are, and per-URL lines are folded back
+// into the URL lists of their domain.
+func LoadBaseline(path string) (map[string]*client.DomainResult, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open baseline: %w", err)
+	}
+	defer file.Close()
+
+	baseline := make(map[string]*client.DomainResult)
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
+	lineNumber := 0
+
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		var fields map[string]json.RawMessage
+		if err := json.Unmarshal([]byte(line), &fields); err != nil {
+			return nil, fmt.Errorf("baseline line %d: %w", lineNumber, err)
+		}
+
+		if _, perURL := fields["url"]; perURL {
+			var record URLRecord
+			if err := json.Unmarshal([]byte(line), &record); err != nil {
+				return nil, fmt.Errorf("baseline line %d: %w", lineNumber, err)
+			}
+			addURLRecord(baseline, record)
+			continue
+		}
+
+		var result client.DomainResult
+		if err := json.Unmarshal([]byte(line), &result); err != nil {
+			return nil, fmt.Errorf("baseline line %d: %w", lineNumber, err)
+		}
+		if result.Domain == "" {
+			return nil, fmt.Errorf("baseline line %d: missing domain", lineNumber)
+		}
+		baseline[strings.ToLower(result.Domain)] = &result
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read baseline: %w", err)
+	}
+
+	return baseline, nil
+}
+
+func addURLRecord(baseline map[string]*client.DomainResult, record URLRecord) {
+	key := strings.ToLower(record.Domain)
+	result, exists := baseline[key]
+	if !exists {
+		result = &client.DomainResult{Domain: record.Domain, ResponseCode: 1}
+		baseline[key] = result
+	}
+
+	if record.Source == string(client.ExtractDetectedURLs) {
+		result.DetectedURLs = append(result.DetectedURLs, client.DetectedURL{
+			URL:       record.URL,
+			Positives: record.Positives,
+			Total:     record.Total,
+			ScanDate:  record.ScanDate,
+		})
+		return
+	}
+
+	result.UndetectedURLs = append(result.UndetectedURLs, client.UndetectedURL{
+		URL:       record.URL,
+		Positives: record.Positives,
+		Total:     record.Total,
+		ScanDate:  record.ScanDate,
+	})
+}
@@ -0,0 +1,134 @@
+package files
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/pluckware/tyvt/internal/client"
+	"github.com/pluckware/tyvt/pkg/diff"
+)
+
+func TestLoadBaseline_PerURL(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "previous.jsonl")
+	h := NewHandler(path, FormatJSONL, client.ExtractSet{client.ExtractUndetectedURLs: true, client.ExtractDetectedURLs: true})
+	h.Open(false)
+	h.AppendResult(testResult())
+	h.Close()
+
+	baseline, err := LoadBaseline(path)
+	if err != nil {
+		t.Fatalf("LoadBaseline failed: %v", err)
+	}
+
+	result := baseline["example.com"]
+	if result == nil || len(result.UndetectedURLs) != 1 || len(result.DetectedURLs) != 1 {
+		t.Fatalf("Expected URL records folded into one domain, got %+v", result)
+	}
+	if result.DetectedURLs[0].Positives != 2 {
+		t.Errorf("Expected detected URL to keep its positives, got %+v", result.DetectedURLs[0])
+	}
+}
+
+func TestLoadBaseline_PerDomain(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "previous.jsonl")
+	h := NewHandler(path, FormatJSONL, client.ExtractSet{client.ExtractSubdomains: true})
+	h.SetJSONLMode(JSONLPerDomain)
+	h.Open(false)
+	h.AppendResult(testResult())
+	h.Close()
+
+	baseline, err := LoadBaseline(path)
+	if err != nil {
+		t.Fatalf("LoadBaseline failed: %v", err)
+	}
+
+	result := baseline["example.com"]
+	if result == nil || len(result.Subdomains) != 1 {
+		t.Errorf("Expected domain record with subdomains, got %+v", result)
+	}
+}
+
+func TestLoadBaseline_Invalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "previous.jsonl")
+	os.WriteFile(path, []byte("{\"domain\":\"example.com\"}\nnot json\n"), 0644)
+
+	if _, err := LoadBaseline(path); err == nil {
+		t.Error("Expected error for invalid baseline line")
+	}
+}
+
+func TestAppendDiff(t *testing.T) {
+	previous := testResult()
+	current := testResult()
+	current.UndetectedURLs = append(current.UndetectedURLs, client.UndetectedURL{URL: "http://example.com/new"})
+	current.Resolutions = nil
+	d := diff.Compare(previous, current)
+
+	t.Run("text", func(t *testing.T) {
+		output := filepath.Join(t.TempDir(), "out.txt")
+		h := NewHandler(output, FormatText, nil)
+		h.Open(false)
+		h.AppendDiff(d)
+		h.Close()
+
+		lines := readOutput(t, output)
+		if len(lines) != 1 || lines[0] != "http://example.com/new" {
+			t.Errorf("Expected only the added URL, got %v", lines)
+		}
+	})
+
+	t.Run("jsonl", func(t *testing.T) {
+		output := filepath.Join(t.TempDir(), "out.jsonl")
+		h := NewHandler(output, FormatJSONL, nil)
+		h.Open(false)
+		h.AppendDiff(d)
+		h.AppendDiff(diff.Compare(previous, previous))
+		h.Close()
+
+		lines := readOutput(t, output)
+		if len(lines) != 1 {
+			t.Fatalf("Expected one line for the changed domain, got %v", lines)
+		}
+
+		var decoded diff.DomainDiff
+		if err := json.Unmarshal([]byte(lines[0]), &decoded); err != nil {
+			t.Fatalf("Line is not valid JSON: %v", err)
+		}
+		if decoded.Added == nil || decoded.Removed == nil || len(decoded.Removed.Resolutions) != 1 {
+			t.Errorf("Expected added and removed parts, got %+v", decoded)
+		}
+	})
+}
+
+func TestAppendDiff_JSONCountsComparedDomains(t *testing.T) {
+	previous := testResult()
+	changed := testResult()
+	changed.UndetectedURLs = append(changed.UndetectedURLs, client.UndetectedURL{URL: "http://example.com/new"})
+
+	output := filepath.Join(t.TempDir(), "out.json")
+	h := NewHandler(output, FormatJSON, nil)
+	h.Open(false)
+	h.AppendDiff(diff.Compare(previous, changed))
+	h.AppendDiff(diff.Compare(previous, previous))
+	h.AddResumedDiff(diff.Compare(previous, previous))
+	h.RecordError("broken.example.com", errors.New("API returned status 500"))
+	if err := h.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	data, _ := os.ReadFile(output)
+	var report Report
+	if err := json.Unmarshal(data, &report); err != nil {
+		t.Fatalf("Invalid report: %v", err)
+	}
+
+	if len(report.Diffs) != 1 {
+		t.Errorf("Expected only the changed domain in diffs, got %d", len(report.Diffs))
+	}
+	if report.Metadata.SuccessCount != 3 || report.Metadata.TotalDomains != 4 || report.Metadata.ErrorCount != 1 {
+		t.Errorf("Expected 3 compared domains out of 4, got %+v", report.Metadata)
+	}
+}
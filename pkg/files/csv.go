@@ -0,0 +1,158 @@
+package files
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/pluckware/tyvt/internal/client"
+)
+
+// Column is a field of the csv and tsv output formats.
+type Column string
+
+const (
+	ColumnDomain    Column = "domain"
+	ColumnURL       Column = "url"
+	ColumnPositives Column = "positives"
+	ColumnTotal     Column = "total"
+	ColumnScanDate  Column = "scan_date"
+	ColumnPath      Column = "path"
+	ColumnQuery     Column = "query"
+	ColumnSource    Column = "source"
+)
+
+// AllColumns lists every supported Column.
+var AllColumns = []Column{
+	ColumnDomain,
+	ColumnURL,
+	ColumnPositives,
+	ColumnTotal,
+	ColumnScanDate,
+	ColumnPath,
+	ColumnQuery,
+	ColumnSource,
+}
+
+// DefaultColumns are used when no columns are configured.
+var DefaultColumns = []Column{
+	ColumnDomain,
+	ColumnURL,
+	ColumnPositives,
+	ColumnTotal,
+	ColumnScanDate,
+}
+
+// ParseColumns parses a comma separated list of column names, keeping the
+// given order.
+func ParseColumns(list string) ([]Column, error) {
+	var columns []Column
+
+	for _, name := range strings.Split(list, ",") {
+		name = strings.TrimSpace(strings.ToLower(name))
+		if name == "" {
+			continue
+		}
+
+		found := false
+		for _, c := range AllColumns {
+			if string(c) == name {
+				columns = append(columns, c)
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return nil, fmt.Errorf("unknown column '%s'", name)
+		}
+	}
+
+	if len(columns) == 0 {
+		return nil, fmt.Errorf("no columns selected")
+	}
+
+	return columns, nil
+}
+
+// columnValue returns the value of a column for a URL record. The path and
+// query columns are empty when the URL cannot be parsed.
+func columnValue(record URLRecord, column Column) string {
+	switch column {
+	case ColumnDomain:
+		return record.Domain
+	case ColumnURL:
+		return record.URL
+	case ColumnPositives:
+		return strconv.Itoa(record.Positives)
+	case ColumnTotal:
+		return strconv.Itoa(record.Total)
+	case ColumnScanDate:
+		return record.ScanDate
+	case ColumnSource:
+		return record.Source
+	case ColumnPath, ColumnQuery:
+		parsed, err := url.Parse(record.URL)
+		if err != nil {
+			return ""
+		}
+		if column == ColumnPath {
+			return parsed.Path
+		}
+		return parsed.RawQuery
+	}
+	return ""
+}
+
+// csvRow renders a single row with the handler's delimiter, quoting fields
+// that contain the delimiter, quotes or line breaks.
+func (h *Handler) csvRow(fields []string) (string, error) {
+	var buf bytes.Buffer
+
+	w := csv.NewWriter(&buf)
+	if h.format == FormatTSV {
+		w.Comma = '\t'
+	}
+
+	if err := w.Write(fields); err != nil {
+		return "", fmt.Errorf("failed to encode row: %w", err)
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return "", fmt.Errorf("failed to encode row: %w", err)
+	}
+
+	return strings.TrimSuffix(buf.String(), "\n"), nil
+}
+
+// csvHeader returns the header row for the configured columns.
+func (h *Handler) csvHeader() (string, error) {
+	fields := make([]string, len(h.columns))
+	for i, c := range h.columns {
+		fields[i] = string(c)
+	}
+	return h.csvRow(fields)
+}
+
+// csvLines encodes the selected URLs of a result as one row each.
+func (h *Handler) csvLines(result *client.DomainResult) ([]string, error) {
+	var lines []string
+
+	for _, record := range URLRecords(result, h.extracts) {
+		fields := make([]string, len(h.columns))
+		for i, c := range h.columns {
+			fields[i] = columnValue(record, c)
+		}
+
+		line, err := h.csvRow(fields)
+		if err != nil {
+			return nil, err
+		}
+		lines = append(lines, line)
+	}
+
+	return lines, nil
+}
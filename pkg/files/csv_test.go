@@ -0,0 +1,109 @@
+package files
+
+import (
+	"encoding/csv"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/pluckware/tyvt/internal/client"
+)
+
+func tableResult() *client.DomainResult {
+	return &client.DomainResult{
+		Domain:       "example.com",
+		ResponseCode: 1,
+		UndetectedURLs: []client.UndetectedURL{
+			{URL: "http://example.com/a,b?x=1&y=\"2\"", Total: 70, ScanDate: "2024-01-02 03:04:05"},
+			{URL: "http://example.com/plain", Total: 71},
+		},
+	}
+}
+
+func TestCSVFormat_QuotesAndColumns(t *testing.T) {
+	output := filepath.Join(t.TempDir(), "results.csv")
+	h := NewHandler(output, FormatCSV, nil)
+	h.SetCSVColumns([]Column{ColumnURL, ColumnPath, ColumnQuery, ColumnTotal}, true)
+
+	if err := h.Open(false); err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	h.AppendResult(tableResult())
+	if err := h.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	file, err := os.Open(output)
+	if err != nil {
+		t.Fatalf("Failed to open output: %v", err)
+	}
+	defer file.Close()
+
+	rows, err := csv.NewReader(file).ReadAll()
+	if err != nil {
+		t.Fatalf("Output is not valid CSV: %v", err)
+	}
+
+	expected := [][]string{
+		{"url", "path", "query", "total"},
+		{"http://example.com/a,b?x=1&y=\"2\"", "/a,b", "x=1&y=\"2\"", "70"},
+		{"http://example.com/plain", "/plain", "", "71"},
+	}
+
+	if len(rows) != len(expected) {
+		t.Fatalf("Expected %d rows, got %v", len(expected), rows)
+	}
+	for i := range expected {
+		for j := range expected[i] {
+			if rows[i][j] != expected[i][j] {
+				t.Errorf("Row %d column %d: expected %q, got %q", i, j, expected[i][j], rows[i][j])
+			}
+		}
+	}
+}
+
+func TestTSVFormat_NoHeader(t *testing.T) {
+	output := filepath.Join(t.TempDir(), "results.tsv")
+	h := NewHandler(output, FormatTSV, nil)
+	h.SetCSVColumns([]Column{ColumnDomain, ColumnURL}, false)
+
+	h.Open(false)
+	h.AppendResult(tableResult())
+	h.Close()
+
+	lines := readOutput(t, output)
+	if len(lines) != 2 {
+		t.Fatalf("Expected 2 rows without header, got %v", lines)
+	}
+	if lines[1] != "example.com\thttp://example.com/plain" {
+		t.Errorf("Unexpected TSV row: %q", lines[1])
+	}
+}
+
+func TestCSVFormat_ResumeSkipsHeader(t *testing.T) {
+	output := filepath.Join(t.TempDir(), "results.csv")
+	os.WriteFile(output, []byte("domain,url,positives,total,scan_date\n"), 0644)
+
+	h := NewHandler(output, FormatCSV, nil)
+	h.Open(true)
+	h.AppendResult(tableResult())
+	h.Close()
+
+	if lines := readOutput(t, output); len(lines) != 3 {
+		t.Errorf("Expected the existing header and 2 rows, got %v", lines)
+	}
+}
+
+func TestParseColumns(t *testing.T) {
+	columns, err := ParseColumns("url, Query")
+	if err != nil {
+		t.Fatalf("ParseColumns failed: %v", err)
+	}
+	if len(columns) != 2 || columns[0] != ColumnURL || columns[1] != ColumnQuery {
+		t.Errorf("Unexpected columns: %v", columns)
+	}
+
+	if _, err := ParseColumns("url,sha256"); err == nil {
+		t.Error("Expected error for unknown column")
+	}
+}
@@ -1,31 +1,233 @@
 package files
 
 import (
+	"bufio"
+	"encoding/json"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"time"
 
 	"github.com/pluckware/tyvt/internal/client"
+	"github.com/pluckware/tyvt/pkg/diff"
+	"github.com/pluckware/tyvt/pkg/logger"
 )
 
+// SyncPolicy controls how eagerly streamed results reach the output file.
+type SyncPolicy string
+
+const (
+	// SyncNone buffers output and only flushes it when the handler is
+	// closed or flushed explicitly with Flush.
+	SyncNone SyncPolicy = "none"
+	// SyncFlush flushes after every result so readers such as `tail -f`
+	// see each domain as soon as it completes.
+	SyncFlush SyncPolicy = "flush"
+	// SyncFsync flushes and fsyncs after every result so that completed
+	// domains also survive a crash of the host.
+	SyncFsync SyncPolicy = "fsync"
+)
+
+// ParseSyncPolicy converts a command line value into a SyncPolicy.
+func ParseSyncPolicy(policy string) (SyncPolicy, error) {
+	switch SyncPolicy(policy) {
+	case SyncNone, SyncFlush, SyncFsync:
+		return SyncPolicy(policy), nil
+	default:
+		return "", fmt.Errorf("unsupported sync policy '%s' (use none, flush or fsync)", policy)
+	}
+}
+
+// Format selects how results are written to the output file.
+type Format string
+
+const (
+	// FormatText writes the selected extracts as plain text, one per line.
+	FormatText Format = "text"
+	// FormatJSON writes a single JSON document with scan metadata, all
+	// results and the per-domain errors once the scan finishes.
+	FormatJSON Format = "json"
+	// FormatJSONL writes one JSON object per line, per domain or per URL
+	// depending on the JSONLMode.
+	FormatJSONL Format = "jsonl"
+	// FormatCSV and FormatTSV write one row per discovered URL with the
+	// configured columns.
+	FormatCSV Format = "csv"
+	FormatTSV Format = "tsv"
+)
+
+// ParseFormat converts a command line value into a Format.
+func ParseFormat(format string) (Format, error) {
+	switch Format(format) {
+	case FormatText, FormatJSON, FormatJSONL, FormatCSV, FormatTSV:
+		return Format(format), nil
+	default:
+		return "", fmt.Errorf("unsupported output format '%s' (use text, json, jsonl, csv or tsv)", format)
+	}
+}
+
+// Handler writes results to the output file or, without one, to stdout, so
+// that they can be piped into other tools. What was written is reported
+// through the logger, never on stdout.
 type Handler struct {
 	outputFile string
+	stdout     io.Writer
+	logger     *logger.Logger
+	format     Format
+	extracts   client.ExtractSet
+	sync       SyncPolicy
+	jsonlMode  JSONLMode
+	columns    []Column
+	header     bool
+
+	opened      bool
+	file        *os.File
+	writer      *bufio.Writer
+	domainCount int
+	lineCount   int
+
+	// Collected for document formats, which are written on Close.
+	scanTime time.Time
+	results  []*client.DomainResult
+	diffs    []*diff.DomainDiff
+	errors   []DomainError
+
+	// diffDomains counts the domains compared in diff mode, changed or
+	// not, as only the changed ones are kept in diffs.
+	diffDomains int
 }
 
+// NewHandler creates a handler that writes results to outputFile, or to
+// stdout if outputFile is empty, in the given format. The text format only
+// writes the selected extracts; a nil extracts selects only undetected URLs.
+func NewHandler(outputFile string, format Format, extracts client.ExtractSet) *Handler {
+	if extracts == nil {
+		extracts = client.DefaultExtracts()
+	}
+
+	if format == "" {
+		format = FormatText
+	}
 
-func NewHandler(outputFile string) *Handler {
 	return &Handler{
 		outputFile: outputFile,
+		stdout:     os.Stdout,
+		logger:     logger.Discard(),
+		format:     format,
+		extracts:   extracts,
+		sync:       SyncFlush,
+		jsonlMode:  JSONLPerURL,
+		columns:    DefaultColumns,
+		header:     true,
+	}
+}
+
+// SetCSVColumns sets the columns of the csv and tsv formats and whether a
+// header row is written. The default is DefaultColumns with a header.
+func (h *Handler) SetCSVColumns(columns []Column, header bool) {
+	h.columns = columns
+	h.header = header
+}
+
+// isTable reports whether the format is csv or tsv.
+func (h *Handler) isTable() bool {
+	return h.format == FormatCSV || h.format == FormatTSV
+}
+
+// SetJSONLMode sets what each jsonl line describes. The default is
+// JSONLPerURL.
+func (h *Handler) SetJSONLMode(mode JSONLMode) {
+	h.jsonlMode = mode
+}
+
+// SetSyncPolicy sets how streamed results are flushed. The default is
+// SyncFlush.
+func (h *Handler) SetSyncPolicy(policy SyncPolicy) {
+	h.sync = policy
+}
+
+// SetLogger reports what was written to l. Nothing is reported by default.
+func (h *Handler) SetLogger(l *logger.Logger) {
+	h.logger = l
+}
+
+// outputName names the output in log records.
+func (h *Handler) outputName() string {
+	if h.outputFile == "" {
+		return "stdout"
 	}
+	return h.outputFile
 }
 
-func (h *Handler) HasOutputFile() bool {
-	return h.outputFile != ""
+// lines returns the plain text output for a result: one value per line for
+// every selected extract, in client.AllExtracts order.
+func (h *Handler) lines(result *client.DomainResult) []string {
+	if result.ResponseCode != 1 {
+		return nil
+	}
+
+	var lines []string
+
+	for _, extract := range client.AllExtracts {
+		if !h.extracts.Has(extract) {
+			continue
+		}
+
+		switch extract {
+		case client.ExtractUndetectedURLs:
+			for _, u := range result.UndetectedURLs {
+				lines = append(lines, u.URL)
+			}
+		case client.ExtractDetectedURLs:
+			for _, u := range result.DetectedURLs {
+				lines = append(lines, u.URL)
+			}
+		case client.ExtractSubdomains:
+			lines = append(lines, result.Subdomains...)
+		case client.ExtractSiblings:
+			lines = append(lines, result.DomainSiblings...)
+		case client.ExtractResolutions:
+			for _, r := range result.Resolutions {
+				lines = append(lines, r.IPAddress)
+			}
+		case client.ExtractSamples:
+			for _, sample := range result.Samples() {
+				lines = append(lines, sample.SHA256)
+			}
+		}
+	}
+
+	return lines
+}
+
+// encode returns the output lines of a result for the streamed formats.
+func (h *Handler) encode(result *client.DomainResult) ([]string, error) {
+	switch {
+	case h.format == FormatJSONL:
+		return h.jsonlLines(result)
+	case h.isTable():
+		return h.csvLines(result)
+	}
+	return h.lines(result), nil
 }
 
-func (h *Handler) WriteResults(results []*client.DomainResult) error {
+// Open prepares the output file for streaming with AppendResult. The file is
+// truncated unless appendMode is set, as when resuming an interrupted run.
+// Without an output file, results are streamed to stdout.
+// Document formats are only written on Close, so for them Open just starts
+// collecting.
+func (h *Handler) Open(appendMode bool) error {
+	h.opened = true
+	h.scanTime = time.Now()
+
+	if h.format == FormatJSON {
+		return nil
+	}
+
 	if h.outputFile == "" {
-		return fmt.Errorf("no output file specified")
+		h.writer = bufio.NewWriter(h.stdout)
+		return h.writeHeader()
 	}
 
 	dir := filepath.Dir(h.outputFile)
@@ -33,63 +235,214 @@ func (h *Handler) WriteResults(results []*client.DomainResult) error {
 		return fmt.Errorf("failed to create output directory: %w", err)
 	}
 
-	var urls []string
-	var filteredResults []*client.DomainResult
-	totalUndetectedURLs := 0
+	flags := os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+	if appendMode {
+		flags = os.O_CREATE | os.O_WRONLY | os.O_APPEND
+	}
 
-	for _, result := range results {
-		if result.ResponseCode == 1 && len(result.UndetectedURLs) > 0 {
-			filteredResults = append(filteredResults, result)
-			totalUndetectedURLs += len(result.UndetectedURLs)
+	file, err := os.OpenFile(h.outputFile, flags, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open output file: %w", err)
+	}
 
-			// Extract URLs for plain text output
-			for _, undetectedURL := range result.UndetectedURLs {
-				urls = append(urls, undetectedURL.URL)
-			}
-		}
+	h.file = file
+	h.writer = bufio.NewWriter(file)
+
+	if appendMode {
+		return nil
+	}
+	return h.writeHeader()
+}
+
+// writeHeader starts a csv or tsv output with the header row, if enabled.
+func (h *Handler) writeHeader() error {
+	if !h.isTable() || !h.header {
+		return nil
 	}
 
-	file, err := os.Create(h.outputFile)
+	header, err := h.csvHeader()
 	if err != nil {
-		return fmt.Errorf("failed to create output file: %w", err)
+		return err
+	}
+	if _, err := fmt.Fprintln(h.writer, header); err != nil {
+		return fmt.Errorf("failed to write header: %w", err)
+	}
+	return h.syncOutput()
+}
+
+// AppendResult streams a single result to the output and applies the sync
+// policy. If Open has not been called, the file is opened in append mode.
+func (h *Handler) AppendResult(result *client.DomainResult) error {
+	if h.format == FormatJSON {
+		h.results = append(h.results, h.extracts.Filter(result))
+		return nil
+	}
+
+	lines, err := h.encode(result)
+	if err != nil {
+		return err
 	}
-	defer file.Close()
 
-	// Write URLs in plain text format, one per line
-	for _, url := range urls {
-		if _, err := fmt.Fprintln(file, url); err != nil {
-			return fmt.Errorf("failed to write URL to file: %w", err)
+	return h.appendLines(lines)
+}
+
+// AppendDiff streams the change in a domain relative to the baseline. The
+// structured formats write the whole diff, added and removed; the text and
+// table formats write only what was added, as they would a result.
+func (h *Handler) AppendDiff(d *diff.DomainDiff) error {
+	h.diffDomains++
+	if d.Empty() {
+		return nil
+	}
+
+	switch {
+	case h.format == FormatJSON:
+		h.diffs = append(h.diffs, d)
+		return nil
+	case h.format == FormatJSONL:
+		line, err := json.Marshal(d)
+		if err != nil {
+			return fmt.Errorf("failed to encode diff: %w", err)
 		}
+		return h.appendLines([]string{string(line)})
+	case d.Added == nil:
+		return nil
 	}
 
-	fmt.Printf("✓ URLs written to %s (%d domains, %d undetected URLs)\n",
-		h.outputFile, len(filteredResults), totalUndetectedURLs)
+	return h.AppendResult(d.Added)
+}
 
-	return nil
+// AddResumedDiff is the diff counterpart of AddResumedResult.
+func (h *Handler) AddResumedDiff(d *diff.DomainDiff) {
+	h.diffDomains++
+	if h.format == FormatJSON && !d.Empty() {
+		h.diffs = append(h.diffs, d)
+	}
 }
 
-func (h *Handler) AppendResult(result *client.DomainResult) error {
-	if h.outputFile == "" {
+// appendLines writes the lines of one domain and applies the sync policy.
+func (h *Handler) appendLines(lines []string) error {
+	if len(lines) == 0 {
 		return nil
 	}
 
-	if result.ResponseCode != 1 || len(result.UndetectedURLs) == 0 {
+	if !h.opened {
+		if err := h.Open(true); err != nil {
+			return err
+		}
+	}
+
+	// Append one value or record per line
+	for _, line := range lines {
+		if _, err := fmt.Fprintln(h.writer, line); err != nil {
+			return fmt.Errorf("failed to append line to file: %w", err)
+		}
+	}
+
+	h.domainCount++
+	h.lineCount += len(lines)
+
+	return h.syncOutput()
+}
+
+func (h *Handler) syncOutput() error {
+	if h.sync == SyncNone {
 		return nil
 	}
 
-	file, err := os.OpenFile(h.outputFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
-	if err != nil {
-		return fmt.Errorf("failed to open output file for appending: %w", err)
+	if err := h.writer.Flush(); err != nil {
+		return fmt.Errorf("failed to flush output file: %w", err)
 	}
-	defer file.Close()
 
-	// Append URLs in plain text format, one per line
-	for _, undetectedURL := range result.UndetectedURLs {
-		if _, err := fmt.Fprintln(file, undetectedURL.URL); err != nil {
-			return fmt.Errorf("failed to append URL to file: %w", err)
+	if h.sync == SyncFsync && h.file != nil {
+		if err := h.file.Sync(); err != nil {
+			return fmt.Errorf("failed to sync output file: %w", err)
 		}
 	}
 
 	return nil
 }
 
+// Flush writes any buffered output through to the output file or stdout,
+// whatever the sync policy. Document formats are only written on Close, so
+// for them it does nothing.
+func (h *Handler) Flush() error {
+	if !h.opened || h.writer == nil {
+		return nil
+	}
+
+	if err := h.writer.Flush(); err != nil {
+		return fmt.Errorf("failed to flush output file: %w", err)
+	}
+	return nil
+}
+
+// AddResumedResult adds a result completed by an earlier, interrupted run.
+// Streamed formats already hold it in the output file; document formats
+// include it in the document.
+func (h *Handler) AddResumedResult(result *client.DomainResult) {
+	if h.format == FormatJSON {
+		h.results = append(h.results, h.extracts.Filter(result))
+	}
+}
+
+// RecordError adds a domain that could not be scanned to the output of
+// formats that report errors.
+func (h *Handler) RecordError(domain string, err error) {
+	if h.format == FormatJSON {
+		h.errors = append(h.errors, DomainError{Domain: domain, Error: err.Error()})
+	}
+}
+
+// Close finishes the output: streamed files are flushed and closed and
+// document formats are written. It reports what was written and is safe to
+// call when nothing was opened.
+func (h *Handler) Close() error {
+	if !h.opened {
+		return nil
+	}
+	h.opened = false
+
+	if h.format == FormatJSON {
+		report := NewReport(h.scanTime, h.results, h.errors)
+		report.Diffs = h.diffs
+		if h.diffDomains > 0 {
+			// In diff mode no results are kept, so count the compared
+			// domains as the successful ones
+			report.Metadata.SuccessCount = h.diffDomains
+			report.Metadata.TotalDomains = h.diffDomains + len(h.errors)
+		}
+
+		if h.outputFile == "" {
+			if err := encodeReport(h.stdout, report); err != nil {
+				return err
+			}
+		} else if err := writeReport(h.outputFile, report); err != nil {
+			return err
+		}
+		h.logger.With("output", h.outputName()).Info("Results written to %s (%d domains, %d errors)",
+			h.outputName(), len(h.results), len(h.errors))
+		return nil
+	}
+
+	file := h.file
+	h.file = nil
+
+	if err := h.writer.Flush(); err != nil {
+		if file != nil {
+			file.Close()
+		}
+		return fmt.Errorf("failed to flush output file: %w", err)
+	}
+
+	if file != nil {
+		if err := file.Close(); err != nil {
+			return fmt.Errorf("failed to close output file: %w", err)
+		}
+	}
+
+	h.logger.With("output", h.outputName()).Info("Results written to %s (%d domains, %d lines)",
+		h.outputName(), h.domainCount, h.lineCount)
+
+	return nil
+}
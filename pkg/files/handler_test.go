@@ -0,0 +1,212 @@
+package files
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/pluckware/tyvt/internal/client"
+	"github.com/pluckware/tyvt/pkg/logger"
+)
+
+func testResult() *client.DomainResult {
+	return &client.DomainResult{
+		Domain:         "example.com",
+		ResponseCode:   1,
+		UndetectedURLs: []client.UndetectedURL{{URL: "http://example.com/a"}},
+		DetectedURLs:   []client.DetectedURL{{URL: "http://example.com/bad", Positives: 2}},
+		Subdomains:     []string{"www.example.com"},
+		Resolutions:    []client.Resolution{{IPAddress: "192.0.2.1"}},
+	}
+}
+
+func readOutput(t *testing.T, path string) []string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read output: %v", err)
+	}
+	return strings.Split(strings.TrimSpace(string(data)), "\n")
+}
+
+func TestAppendResult_DefaultExtracts(t *testing.T) {
+	output := filepath.Join(t.TempDir(), "out.txt")
+	h := NewHandler(output, FormatText, nil)
+
+	if err := h.Open(false); err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	if err := h.AppendResult(testResult()); err != nil {
+		t.Fatalf("AppendResult failed: %v", err)
+	}
+	if err := h.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	lines := readOutput(t, output)
+	if len(lines) != 1 || lines[0] != "http://example.com/a" {
+		t.Errorf("Expected only undetected URLs, got %v", lines)
+	}
+}
+
+func TestAppendResult_SelectedExtracts(t *testing.T) {
+	output := filepath.Join(t.TempDir(), "out.txt")
+	extracts := client.ExtractSet{client.ExtractDetectedURLs: true, client.ExtractSubdomains: true, client.ExtractResolutions: true}
+	h := NewHandler(output, FormatText, extracts)
+
+	if err := h.Open(false); err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	if err := h.AppendResult(testResult()); err != nil {
+		t.Fatalf("AppendResult failed: %v", err)
+	}
+	if err := h.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	expected := []string{"http://example.com/bad", "www.example.com", "192.0.2.1"}
+	lines := readOutput(t, output)
+	if strings.Join(lines, ",") != strings.Join(expected, ",") {
+		t.Errorf("Expected %v, got %v", expected, lines)
+	}
+}
+
+func TestAppendResult_SkipsEmptyResults(t *testing.T) {
+	output := filepath.Join(t.TempDir(), "out.txt")
+	h := NewHandler(output, FormatText, nil)
+
+	if err := h.AppendResult(&client.DomainResult{Domain: "example.com", ResponseCode: 0}); err != nil {
+		t.Fatalf("AppendResult failed: %v", err)
+	}
+
+	if _, err := os.Stat(output); !os.IsNotExist(err) {
+		t.Error("Expected no output file for an empty result")
+	}
+}
+
+func TestAppendResult_StreamsWithFlushPolicy(t *testing.T) {
+	output := filepath.Join(t.TempDir(), "out.txt")
+	h := NewHandler(output, FormatText, nil)
+
+	if err := h.Open(false); err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer h.Close()
+
+	if err := h.AppendResult(testResult()); err != nil {
+		t.Fatalf("AppendResult failed: %v", err)
+	}
+
+	// Visible before Close, as a `tail -f` reader would see it
+	lines := readOutput(t, output)
+	if len(lines) != 1 || lines[0] != "http://example.com/a" {
+		t.Errorf("Expected streamed line before close, got %v", lines)
+	}
+}
+
+func TestAppendResult_NonePolicyBuffersUntilClose(t *testing.T) {
+	output := filepath.Join(t.TempDir(), "out.txt")
+	h := NewHandler(output, FormatText, nil)
+	h.SetSyncPolicy(SyncNone)
+
+	if err := h.Open(false); err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+
+	if err := h.AppendResult(testResult()); err != nil {
+		t.Fatalf("AppendResult failed: %v", err)
+	}
+
+	if data, _ := os.ReadFile(output); len(data) != 0 {
+		t.Errorf("Expected output to be buffered, got %q", data)
+	}
+
+	if err := h.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	if lines := readOutput(t, output); len(lines) != 1 {
+		t.Errorf("Expected buffered line after close, got %v", lines)
+	}
+}
+
+func TestOpen_AppendModeKeepsEarlierResults(t *testing.T) {
+	output := filepath.Join(t.TempDir(), "out.txt")
+	os.WriteFile(output, []byte("http://example.com/earlier\n"), 0644)
+
+	h := NewHandler(output, FormatText, nil)
+	if err := h.Open(true); err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	h.AppendResult(testResult())
+	h.Close()
+
+	lines := readOutput(t, output)
+	if len(lines) != 2 || lines[0] != "http://example.com/earlier" {
+		t.Errorf("Expected earlier results to be kept, got %v", lines)
+	}
+}
+
+func TestAppendResult_StreamsToStdoutWithoutOutputFile(t *testing.T) {
+	var stdout, logs bytes.Buffer
+	h := NewHandler("", FormatCSV, nil)
+	h.stdout = &stdout
+	h.SetLogger(logger.NewWithOptions(logger.Options{Output: &logs}))
+
+	if err := h.Open(true); err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	if err := h.AppendResult(testResult()); err != nil {
+		t.Fatalf("AppendResult failed: %v", err)
+	}
+	if stdout.Len() == 0 {
+		t.Error("Expected the result on stdout before Close")
+	}
+	if err := h.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(stdout.String()), "\n")
+	if len(lines) != 2 || lines[0] != "domain,url,positives,total,scan_date" {
+		t.Errorf("Expected header and one row on stdout, got %v", lines)
+	}
+	if strings.Contains(stdout.String(), "Results written") {
+		t.Error("Summary must not be written to stdout")
+	}
+	if !strings.Contains(logs.String(), "Results written to stdout (1 domains, 1 lines)") {
+		t.Errorf("Expected summary in the log, got %q", logs.String())
+	}
+}
+
+func TestClose_WritesJSONReportToStdout(t *testing.T) {
+	var stdout bytes.Buffer
+	h := NewHandler("", FormatJSON, nil)
+	h.stdout = &stdout
+
+	h.Open(false)
+	h.AppendResult(testResult())
+	if err := h.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	var report Report
+	if err := json.Unmarshal(stdout.Bytes(), &report); err != nil {
+		t.Fatalf("Expected a JSON report on stdout: %v", err)
+	}
+	if len(report.Results) != 1 {
+		t.Errorf("Expected 1 result, got %d", len(report.Results))
+	}
+}
+
+func TestParseSyncPolicy(t *testing.T) {
+	for _, policy := range []string{"none", "flush", "fsync"} {
+		if _, err := ParseSyncPolicy(policy); err != nil {
+			t.Errorf("ParseSyncPolicy(%q) unexpected error: %v", policy, err)
+		}
+	}
+	if _, err := ParseSyncPolicy("always"); err == nil {
+		t.Error("Expected error for unknown sync policy")
+	}
+}
@@ -0,0 +1,136 @@
+package files
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pluckware/tyvt/internal/client"
+)
+
+// JournalEntry is one line of the checkpoint journal: the outcome of a
+// single domain lookup.
+type JournalEntry struct {
+	Domain string               `json:"domain"`
+	Result *client.DomainResult `json:"result,omitempty"`
+	Error  string               `json:"error,omitempty"`
+	Time   time.Time            `json:"time"`
+}
+
+// Journal is an append-only JSON Lines log of completed domains. Every
+// entry is synced to disk before Record returns, so an interrupted run
+// loses at most the lookup that was in flight.
+type Journal struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+// OpenJournal opens the checkpoint journal at path. With resume set, the
+// existing entries are read back and new entries are appended; otherwise
+// the journal is truncated. A partially written final line, left behind by
+// a crash, is ignored.
+func OpenJournal(path string, resume bool) (*Journal, []JournalEntry, error) {
+	if dir := filepath.Dir(path); dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, nil, fmt.Errorf("failed to create checkpoint directory: %w", err)
+		}
+	}
+
+	var entries []JournalEntry
+	flags := os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+
+	if resume {
+		var err error
+		entries, err = readJournal(path)
+		if err != nil {
+			return nil, nil, err
+		}
+		flags = os.O_CREATE | os.O_WRONLY | os.O_APPEND
+	}
+
+	file, err := os.OpenFile(path, flags, 0644)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open checkpoint file: %w", err)
+	}
+
+	return &Journal{file: file}, entries, nil
+}
+
+func readJournal(path string) ([]JournalEntry, error) {
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open checkpoint file: %w", err)
+	}
+	defer file.Close()
+
+	var entries []JournalEntry
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
+
+	for scanner.Scan() {
+		var entry JournalEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			continue
+		}
+		entries = append(entries, entry)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read checkpoint file: %w", err)
+	}
+
+	return entries, nil
+}
+
+// Record appends the outcome of a domain lookup and syncs it to disk.
+func (j *Journal) Record(entry JournalEntry) error {
+	if entry.Time.IsZero() {
+		entry.Time = time.Now()
+	}
+
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to encode checkpoint entry: %w", err)
+	}
+	line = append(line, '\n')
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if _, err := j.file.Write(line); err != nil {
+		return fmt.Errorf("failed to write checkpoint entry: %w", err)
+	}
+
+	if err := j.file.Sync(); err != nil {
+		return fmt.Errorf("failed to sync checkpoint file: %w", err)
+	}
+
+	return nil
+}
+
+func (j *Journal) Close() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.file.Close()
+}
+
+// CompletedResults returns the latest successful result for every domain in
+// entries, keyed by lower-cased domain. Failed lookups are left out so that
+// they are retried on resume.
+func CompletedResults(entries []JournalEntry) map[string]*client.DomainResult {
+	completed := make(map[string]*client.DomainResult)
+	for _, entry := range entries {
+		if entry.Error == "" && entry.Result != nil {
+			completed[strings.ToLower(entry.Domain)] = entry.Result
+		}
+	}
+	return completed
+}
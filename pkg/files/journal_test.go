@@ -0,0 +1,76 @@
+package files
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/pluckware/tyvt/internal/client"
+)
+
+func TestJournal_ResumeReadsEntries(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "scan.checkpoint")
+
+	journal, entries, err := OpenJournal(path, false)
+	if err != nil {
+		t.Fatalf("OpenJournal failed: %v", err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("Expected no entries for a new journal, got %d", len(entries))
+	}
+
+	journal.Record(JournalEntry{Domain: "Example.com", Result: testResult()})
+	journal.Record(JournalEntry{Domain: "failed.com", Error: errors.New("boom").Error()})
+	journal.Close()
+
+	// Simulate a crash in the middle of writing an entry
+	file, _ := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+	file.WriteString(`{"domain": "partial.com", "res`)
+	file.Close()
+
+	journal, entries, err = OpenJournal(path, true)
+	if err != nil {
+		t.Fatalf("OpenJournal with resume failed: %v", err)
+	}
+	defer journal.Close()
+
+	if len(entries) != 2 {
+		t.Fatalf("Expected 2 complete entries, got %d", len(entries))
+	}
+
+	completed := CompletedResults(entries)
+	if len(completed) != 1 {
+		t.Fatalf("Expected only the successful domain to be completed, got %v", completed)
+	}
+	if result := completed["example.com"]; result == nil || len(result.UndetectedURLs) != 1 {
+		t.Errorf("Expected the stored result for example.com, got %+v", result)
+	}
+}
+
+func TestJournal_WithoutResumeTruncates(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "scan.checkpoint")
+
+	journal, _, err := OpenJournal(path, false)
+	if err != nil {
+		t.Fatalf("OpenJournal failed: %v", err)
+	}
+	journal.Record(JournalEntry{Domain: "example.com", Result: &client.DomainResult{Domain: "example.com"}})
+	journal.Close()
+
+	journal, _, err = OpenJournal(path, false)
+	if err != nil {
+		t.Fatalf("OpenJournal failed: %v", err)
+	}
+	journal.Close()
+
+	journal, entries, err := OpenJournal(path, true)
+	if err != nil {
+		t.Fatalf("OpenJournal with resume failed: %v", err)
+	}
+	defer journal.Close()
+
+	if len(entries) != 0 {
+		t.Errorf("Expected journal to be truncated, got %d entries", len(entries))
+	}
+}
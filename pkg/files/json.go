@@ -0,0 +1,101 @@
+package files
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/pluckware/tyvt/internal/client"
+	"github.com/pluckware/tyvt/pkg/diff"
+)
+
+// Report is the JSON document written by the json output format. Diffs is
+// only set when comparing against a baseline.
+type Report struct {
+	Metadata ReportMetadata         `json:"metadata"`
+	Results  []*client.DomainResult `json:"results"`
+	Diffs    []*diff.DomainDiff     `json:"diffs,omitempty"`
+	Errors   []DomainError          `json:"errors,omitempty"`
+}
+
+type ReportMetadata struct {
+	ScanTime     time.Time `json:"scan_time"`
+	TotalDomains int       `json:"total_domains"`
+	SuccessCount int       `json:"success_count"`
+	ErrorCount   int       `json:"error_count"`
+	Version      string    `json:"version"`
+}
+
+// DomainError is a domain that could not be scanned.
+type DomainError struct {
+	Domain string `json:"domain"`
+	Error  string `json:"error"`
+}
+
+// NewReport builds a report from the results and errors of a scan started
+// at scanTime.
+func NewReport(scanTime time.Time, results []*client.DomainResult, errors []DomainError) *Report {
+	if results == nil {
+		results = []*client.DomainResult{}
+	}
+
+	return &Report{
+		Metadata: ReportMetadata{
+			ScanTime:     scanTime.UTC(),
+			TotalDomains: len(results) + len(errors),
+			SuccessCount: len(results),
+			ErrorCount:   len(errors),
+			Version:      client.Version,
+		},
+		Results: results,
+		Errors:  errors,
+	}
+}
+
+// writeReport writes the report to path through a temporary file, so a
+// reader never sees a half written document.
+func writeReport(path string, report *Report) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create output directory: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(dir, filepath.Base(path)+".tmp*")
+	if err != nil {
+		return fmt.Errorf("failed to create output file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := tmp.Chmod(0644); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to create output file: %w", err)
+	}
+
+	if err := encodeReport(tmp, report); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write JSON report: %w", err)
+	}
+
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to write JSON report: %w", err)
+	}
+
+	return nil
+}
+
+// encodeReport writes the report to w as indented JSON.
+func encodeReport(w io.Writer, report *Report) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(report); err != nil {
+		return fmt.Errorf("failed to write JSON report: %w", err)
+	}
+	return nil
+}
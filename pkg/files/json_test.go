@@ -0,0 +1,88 @@
+package files
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/pluckware/tyvt/internal/client"
+)
+
+func TestJSONFormat_WritesDocumentedSchema(t *testing.T) {
+	output := filepath.Join(t.TempDir(), "results.json")
+	h := NewHandler(output, FormatJSON, nil)
+
+	if err := h.Open(false); err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+
+	h.AppendResult(testResult())
+	h.AddResumedResult(&client.DomainResult{Domain: "resumed.com", ResponseCode: 1})
+	h.RecordError("failed.com", errors.New("API returned status 500"))
+
+	if err := h.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	data, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatalf("Failed to read output: %v", err)
+	}
+
+	var report Report
+	if err := json.Unmarshal(data, &report); err != nil {
+		t.Fatalf("Output is not a valid JSON report: %v", err)
+	}
+
+	meta := report.Metadata
+	if meta.TotalDomains != 3 || meta.SuccessCount != 2 || meta.ErrorCount != 1 {
+		t.Errorf("Unexpected metadata counts: %+v", meta)
+	}
+	if meta.Version != client.Version || meta.ScanTime.IsZero() {
+		t.Errorf("Expected version and scan time in metadata: %+v", meta)
+	}
+
+	if len(report.Results) != 2 || report.Results[0].Domain != "example.com" {
+		t.Fatalf("Unexpected results: %+v", report.Results)
+	}
+	if len(report.Results[0].UndetectedURLs) != 1 {
+		t.Errorf("Expected undetected URLs in result")
+	}
+	if report.Results[0].Subdomains != nil {
+		t.Errorf("Expected unselected extracts to be left out, got %v", report.Results[0].Subdomains)
+	}
+
+	if len(report.Errors) != 1 || report.Errors[0].Domain != "failed.com" {
+		t.Errorf("Unexpected errors: %+v", report.Errors)
+	}
+}
+
+func TestJSONFormat_EmptyScanHasResultsArray(t *testing.T) {
+	output := filepath.Join(t.TempDir(), "results.json")
+	h := NewHandler(output, FormatJSON, nil)
+
+	h.Open(false)
+	if err := h.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	var doc map[string]json.RawMessage
+	data, _ := os.ReadFile(output)
+	if err := json.Unmarshal(data, &doc); err != nil {
+		t.Fatalf("Output is not valid JSON: %v", err)
+	}
+	if string(doc["results"]) != "[]" {
+		t.Errorf("Expected empty results array, got %s", doc["results"])
+	}
+}
+
+func TestParseFormat(t *testing.T) {
+	if _, err := ParseFormat("json"); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	if _, err := ParseFormat("xml"); err == nil {
+		t.Error("Expected error for unknown format")
+	}
+}
@@ -0,0 +1,94 @@
+package files
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/pluckware/tyvt/internal/client"
+)
+
+// JSONLMode selects what each line of the jsonl output format describes.
+type JSONLMode string
+
+const (
+	// JSONLPerURL writes one URLRecord per discovered URL.
+	JSONLPerURL JSONLMode = "url"
+	// JSONLPerDomain writes one DomainResult per scanned domain.
+	JSONLPerDomain JSONLMode = "domain"
+)
+
+// ParseJSONLMode converts a command line value into a JSONLMode.
+func ParseJSONLMode(mode string) (JSONLMode, error) {
+	switch JSONLMode(mode) {
+	case JSONLPerURL, JSONLPerDomain:
+		return JSONLMode(mode), nil
+	default:
+		return "", fmt.Errorf("unsupported jsonl mode '%s' (use url or domain)", mode)
+	}
+}
+
+// URLRecord is a single discovered URL. Source names the report list the
+// URL came from, such as undetected_urls.
+type URLRecord struct {
+	Domain    string `json:"domain"`
+	URL       string `json:"url"`
+	Positives int    `json:"positives"`
+	Total     int    `json:"total"`
+	ScanDate  string `json:"scan_date"`
+	Source    string `json:"source"`
+}
+
+// URLRecords flattens the selected URL lists of a result into records.
+func URLRecords(result *client.DomainResult, extracts client.ExtractSet) []URLRecord {
+	var records []URLRecord
+
+	if extracts.Has(client.ExtractUndetectedURLs) {
+		for _, u := range result.UndetectedURLs {
+			records = append(records, URLRecord{
+				Domain:    result.Domain,
+				URL:       u.URL,
+				Positives: u.Positives,
+				Total:     u.Total,
+				ScanDate:  u.ScanDate,
+				Source:    string(client.ExtractUndetectedURLs),
+			})
+		}
+	}
+
+	if extracts.Has(client.ExtractDetectedURLs) {
+		for _, u := range result.DetectedURLs {
+			records = append(records, URLRecord{
+				Domain:    result.Domain,
+				URL:       u.URL,
+				Positives: u.Positives,
+				Total:     u.Total,
+				ScanDate:  u.ScanDate,
+				Source:    string(client.ExtractDetectedURLs),
+			})
+		}
+	}
+
+	return records
+}
+
+// jsonlLines encodes a result as JSON Lines according to the handler's mode.
+func (h *Handler) jsonlLines(result *client.DomainResult) ([]string, error) {
+	if h.jsonlMode == JSONLPerDomain {
+		line, err := json.Marshal(h.extracts.Filter(result))
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode result: %w", err)
+		}
+		return []string{string(line)}, nil
+	}
+
+	var lines []string
+	for _, record := range URLRecords(result, h.extracts) {
+		line, err := json.Marshal(record)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode URL record: %w", err)
+		}
+		lines = append(lines, string(line))
+	}
+
+	return lines, nil
+}
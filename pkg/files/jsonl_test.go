@@ -0,0 +1,73 @@
+package files
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"testing"
+
+	"github.com/pluckware/tyvt/internal/client"
+)
+
+func TestJSONLFormat_PerURL(t *testing.T) {
+	output := filepath.Join(t.TempDir(), "results.jsonl")
+	extracts := client.ExtractSet{client.ExtractUndetectedURLs: true, client.ExtractDetectedURLs: true}
+	h := NewHandler(output, FormatJSONL, extracts)
+
+	if err := h.Open(false); err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer h.Close()
+
+	if err := h.AppendResult(testResult()); err != nil {
+		t.Fatalf("AppendResult failed: %v", err)
+	}
+
+	// Lines are streamed, so they are readable before Close
+	lines := readOutput(t, output)
+	if len(lines) != 2 {
+		t.Fatalf("Expected 2 URL records, got %v", lines)
+	}
+
+	var record URLRecord
+	if err := json.Unmarshal([]byte(lines[1]), &record); err != nil {
+		t.Fatalf("Line is not valid JSON: %v", err)
+	}
+
+	expected := URLRecord{Domain: "example.com", URL: "http://example.com/bad", Positives: 2, Source: "detected_urls"}
+	if record != expected {
+		t.Errorf("Expected %+v, got %+v", expected, record)
+	}
+}
+
+func TestJSONLFormat_PerDomain(t *testing.T) {
+	output := filepath.Join(t.TempDir(), "results.jsonl")
+	h := NewHandler(output, FormatJSONL, nil)
+	h.SetJSONLMode(JSONLPerDomain)
+
+	h.Open(false)
+	h.AppendResult(testResult())
+	h.AppendResult(&client.DomainResult{Domain: "unknown.com"})
+	h.Close()
+
+	lines := readOutput(t, output)
+	if len(lines) != 2 {
+		t.Fatalf("Expected one line per domain, got %v", lines)
+	}
+
+	var result client.DomainResult
+	if err := json.Unmarshal([]byte(lines[0]), &result); err != nil {
+		t.Fatalf("Line is not valid JSON: %v", err)
+	}
+	if result.Domain != "example.com" || len(result.UndetectedURLs) != 1 {
+		t.Errorf("Unexpected domain record: %+v", result)
+	}
+}
+
+func TestParseJSONLMode(t *testing.T) {
+	if _, err := ParseJSONLMode("domain"); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	if _, err := ParseJSONLMode("line"); err == nil {
+		t.Error("Expected error for unknown jsonl mode")
+	}
+}
@@ -1,59 +1,139 @@
 package logger
 
 import (
+	"context"
 	"fmt"
-	"log"
+	"io"
+	"log/slog"
 	"os"
-	"time"
+	"strings"
+
+	"github.com/pluckware/tyvt/pkg/validation"
+)
+
+// Level is the minimum severity of the records a Logger writes.
+type Level = slog.Level
+
+const (
+	LevelDebug = slog.LevelDebug
+	LevelInfo  = slog.LevelInfo
+	LevelWarn  = slog.LevelWarn
+	LevelError = slog.LevelError
 )
 
-type Level int
+// ParseLevel converts a command line value into a Level.
+func ParseLevel(level string) (Level, error) {
+	switch strings.ToLower(level) {
+	case "debug":
+		return LevelDebug, nil
+	case "info":
+		return LevelInfo, nil
+	case "warn", "warning":
+		return LevelWarn, nil
+	case "error":
+		return LevelError, nil
+	default:
+		return 0, fmt.Errorf("unsupported log level '%s' (use debug, info, warn or error)", level)
+	}
+}
+
+// Format selects how log records are encoded.
+type Format string
 
 const (
-	LevelDebug Level = iota
-	LevelInfo
-	LevelWarn
-	LevelError
+	FormatText Format = "text"
+	FormatJSON Format = "json"
 )
 
+// ParseFormat converts a command line value into a Format.
+func ParseFormat(format string) (Format, error) {
+	switch Format(strings.ToLower(format)) {
+	case FormatText:
+		return FormatText, nil
+	case FormatJSON:
+		return FormatJSON, nil
+	default:
+		return "", fmt.Errorf("unsupported log format '%s' (use text or json)", format)
+	}
+}
+
+// Options configures a Logger. Output defaults to stderr, which keeps
+// stdout free for results, and Format to FormatText. Without a
+// Redactor, only apikey query parameters are masked.
+type Options struct {
+	Level    Level
+	Format   Format
+	Output   io.Writer
+	Redactor *validation.Redactor
+}
+
+// Logger writes leveled records through log/slog. Messages are formatted
+// printf style; attributes added with With, such as the domain or key_id
+// of an event, are kept as separate fields so that log pipelines can index
+// them. API keys are redacted from messages and string attributes.
 type Logger struct {
-	level  Level
-	logger *log.Logger
+	logger *slog.Logger
 }
 
+// New creates a text logger writing to stderr.
 func New(level Level) *Logger {
-	return &Logger{
-		level:  level,
-		logger: log.New(os.Stdout, "", 0),
-	}
+	return NewWithOptions(Options{Level: level})
 }
 
-func (l *Logger) Debug(format string, args ...interface{}) {
-	if l.level <= LevelDebug {
-		l.log("DEBUG", format, args...)
+// NewWithOptions creates a logger configured by opts.
+func NewWithOptions(opts Options) *Logger {
+	output := opts.Output
+	if output == nil {
+		output = os.Stderr
+	}
+	redactor := opts.Redactor
+	if redactor == nil {
+		redactor = validation.NewRedactor(nil)
 	}
-}
 
-func (l *Logger) Info(format string, args ...interface{}) {
-	if l.level <= LevelInfo {
-		l.log("INFO", format, args...)
+	handlerOpts := &slog.HandlerOptions{Level: opts.Level}
+	var handler slog.Handler
+	if opts.Format == FormatJSON {
+		handler = slog.NewJSONHandler(output, handlerOpts)
+	} else {
+		handler = slog.NewTextHandler(output, handlerOpts)
 	}
+
+	return &Logger{logger: slog.New(&redactHandler{handler: handler, redactor: redactor})}
 }
 
-func (l *Logger) Warn(format string, args ...interface{}) {
-	if l.level <= LevelWarn {
-		l.log("WARN", format, args...)
-	}
+// Discard returns a logger that writes nothing, for components that log
+// only once given a logger.
+func Discard() *Logger {
+	return &Logger{logger: slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{Level: LevelError + 1}))}
 }
 
-func (l *Logger) Error(format string, args ...interface{}) {
-	if l.level <= LevelError {
-		l.log("ERROR", format, args...)
-	}
+// With returns a logger that adds the given key-value pairs as attributes
+// to every record.
+func (l *Logger) With(args ...any) *Logger {
+	return &Logger{logger: l.logger.With(args...)}
+}
+
+func (l *Logger) Debug(format string, args ...any) {
+	l.log(LevelDebug, format, args...)
+}
+
+func (l *Logger) Info(format string, args ...any) {
+	l.log(LevelInfo, format, args...)
+}
+
+func (l *Logger) Warn(format string, args ...any) {
+	l.log(LevelWarn, format, args...)
 }
 
-func (l *Logger) log(level string, format string, args ...interface{}) {
-	timestamp := time.Now().Format("2006-01-02 15:04:05")
-	message := fmt.Sprintf(format, args...)
-	l.logger.Printf("[%s] %s: %s", timestamp, level, message)
-}
\ No newline at end of file
+func (l *Logger) Error(format string, args ...any) {
+	l.log(LevelError, format, args...)
+}
+
+func (l *Logger) log(level Level, format string, args ...any) {
+	ctx := context.Background()
+	if !l.logger.Enabled(ctx, level) {
+		return
+	}
+	l.logger.Log(ctx, level, fmt.Sprintf(format, args...))
+}
@@ -0,0 +1,80 @@
+package logger
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/pluckware/tyvt/pkg/validation"
+)
+
+const testAPIKey = "c9a9cfea8329cdf114760ed36fc8468dd1a1cb826d4adab9fee96bad9ec74add"
+
+func TestLogger_RedactsKnownKeys(t *testing.T) {
+	var buf bytes.Buffer
+	l := NewWithOptions(Options{
+		Level:    LevelDebug,
+		Output:   &buf,
+		Redactor: validation.NewRedactor([]string{testAPIKey}),
+	})
+
+	l.Debug("Using key %s", testAPIKey)
+	l.With("error", errors.New(`Get "https://example.com/users/`+testAPIKey+`/overall_quotas": EOF`)).Error("Check failed")
+
+	if strings.Contains(buf.String(), testAPIKey) {
+		t.Errorf("Log output leaks the API key:\n%s", buf.String())
+	}
+	if strings.Count(buf.String(), "****4add") != 2 {
+		t.Errorf("Expected both keys masked, got:\n%s", buf.String())
+	}
+}
+
+func TestLogger_RedactsQueryKeysWithoutRedactor(t *testing.T) {
+	var buf bytes.Buffer
+	l := NewWithOptions(Options{Output: &buf})
+
+	l.Warn("request to https://example.com/report?apikey=%s&domain=example.com failed", testAPIKey)
+	l.With("url", "https://example.com/report?apikey="+testAPIKey).Warn("Request failed")
+
+	if strings.Contains(buf.String(), testAPIKey) {
+		t.Errorf("Log output leaks the API key:\n%s", buf.String())
+	}
+}
+
+func TestLogger_JSONAttributes(t *testing.T) {
+	var buf bytes.Buffer
+	l := NewWithOptions(Options{Level: LevelInfo, Format: FormatJSON, Output: &buf})
+
+	l.Debug("not written")
+	l.With("domain", "example.com", "attempt", 2, "status", 429).Warn("Retrying request in %s", "5s")
+
+	var record map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+		t.Fatalf("Expected a single JSON record, got %q: %v", buf.String(), err)
+	}
+
+	if record["level"] != "WARN" || record["msg"] != "Retrying request in 5s" {
+		t.Errorf("Unexpected level or message: %v", record)
+	}
+	if record["domain"] != "example.com" || record["attempt"] != 2.0 || record["status"] != 429.0 {
+		t.Errorf("Expected attributes as fields, got %v", record)
+	}
+}
+
+func TestParseLevel(t *testing.T) {
+	for input, want := range map[string]Level{"debug": LevelDebug, "INFO": LevelInfo, "warn": LevelWarn, "error": LevelError} {
+		got, err := ParseLevel(input)
+		if err != nil || got != want {
+			t.Errorf("ParseLevel(%q) = %v, %v; want %v", input, got, err, want)
+		}
+	}
+
+	if _, err := ParseLevel("verbose"); err == nil {
+		t.Error("Expected error for unknown level")
+	}
+	if _, err := ParseFormat("xml"); err == nil {
+		t.Error("Expected error for unknown format")
+	}
+}
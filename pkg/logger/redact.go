@@ -0,0 +1,63 @@
+package logger
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/pluckware/tyvt/pkg/validation"
+)
+
+// redactHandler masks API keys in the message and the string and error
+// attributes of every record before passing it on to handler.
+type redactHandler struct {
+	handler  slog.Handler
+	redactor *validation.Redactor
+}
+
+func (h *redactHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.handler.Enabled(ctx, level)
+}
+
+func (h *redactHandler) Handle(ctx context.Context, record slog.Record) error {
+	redacted := slog.NewRecord(record.Time, record.Level, h.redactor.Redact(record.Message), record.PC)
+	record.Attrs(func(attr slog.Attr) bool {
+		redacted.AddAttrs(h.redact(attr))
+		return true
+	})
+	return h.handler.Handle(ctx, redacted)
+}
+
+func (h *redactHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	redacted := make([]slog.Attr, len(attrs))
+	for i, attr := range attrs {
+		redacted[i] = h.redact(attr)
+	}
+	return &redactHandler{handler: h.handler.WithAttrs(redacted), redactor: h.redactor}
+}
+
+func (h *redactHandler) WithGroup(name string) slog.Handler {
+	return &redactHandler{handler: h.handler.WithGroup(name), redactor: h.redactor}
+}
+
+// redact returns attr with any API key in its value masked.
+func (h *redactHandler) redact(attr slog.Attr) slog.Attr {
+	value := attr.Value.Resolve()
+
+	switch value.Kind() {
+	case slog.KindString:
+		return slog.String(attr.Key, h.redactor.Redact(value.String()))
+	case slog.KindGroup:
+		group := value.Group()
+		redacted := make([]any, len(group))
+		for i, member := range group {
+			redacted[i] = h.redact(member)
+		}
+		return slog.Group(attr.Key, redacted...)
+	case slog.KindAny:
+		if err, ok := value.Any().(error); ok {
+			return slog.String(attr.Key, h.redactor.Redact(err.Error()))
+		}
+	}
+
+	return slog.Attr{Key: attr.Key, Value: value}
+}
@@ -0,0 +1,234 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/pluckware/tyvt/internal/client"
+	"github.com/pluckware/tyvt/pkg/files"
+
+	_ "modernc.org/sqlite"
+)
+
+const schema = `
+CREATE TABLE IF NOT EXISTS scans (
+	id            INTEGER PRIMARY KEY AUTOINCREMENT,
+	started_at    TEXT NOT NULL,
+	finished_at   TEXT,
+	success_count INTEGER NOT NULL DEFAULT 0,
+	error_count   INTEGER NOT NULL DEFAULT 0,
+	version       TEXT NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS domains (
+	domain     TEXT PRIMARY KEY,
+	first_seen TEXT NOT NULL,
+	last_seen  TEXT NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS domain_scans (
+	scan_id       INTEGER NOT NULL REFERENCES scans(id),
+	domain        TEXT NOT NULL REFERENCES domains(domain),
+	scanned_at    TEXT NOT NULL,
+	response_code INTEGER NOT NULL,
+	url_count     INTEGER NOT NULL,
+	PRIMARY KEY (scan_id, domain)
+);
+
+CREATE TABLE IF NOT EXISTS urls (
+	domain        TEXT NOT NULL REFERENCES domains(domain),
+	url           TEXT NOT NULL,
+	source        TEXT NOT NULL,
+	positives     INTEGER NOT NULL,
+	total         INTEGER NOT NULL,
+	scan_date     TEXT NOT NULL,
+	first_seen    TEXT NOT NULL,
+	last_seen     TEXT NOT NULL,
+	first_scan_id INTEGER NOT NULL REFERENCES scans(id),
+	last_scan_id  INTEGER NOT NULL REFERENCES scans(id),
+	PRIMARY KEY (domain, url)
+);
+`
+
+// urlExtracts selects every URL list of a result for storage, regardless of
+// what was selected for the output file.
+var urlExtracts = client.ExtractSet{
+	client.ExtractUndetectedURLs: true,
+	client.ExtractDetectedURLs:   true,
+}
+
+// Store is an SQLite database that keeps the history of every scan: which
+// domains were scanned when, and when each URL was first and last seen.
+type Store struct {
+	db     *sql.DB
+	scanID int64
+}
+
+// StoredURL is a URL record with its first-seen and last-seen times.
+type StoredURL struct {
+	files.URLRecord
+	FirstSeen time.Time `json:"first_seen"`
+	LastSeen  time.Time `json:"last_seen"`
+}
+
+// Open opens or creates the database at path and ensures the schema exists.
+func Open(path string) (*Store, error) {
+	if dir := filepath.Dir(path); dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, fmt.Errorf("failed to create database directory: %w", err)
+		}
+	}
+
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+
+	// SQLite allows a single writer; one connection avoids lock errors
+	db.SetMaxOpenConns(1)
+
+	if _, err := db.Exec(schema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to create database schema: %w", err)
+	}
+
+	return &Store{db: db}, nil
+}
+
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// BeginScan records the start of a scan. Results recorded afterwards belong
+// to this scan.
+func (s *Store) BeginScan(startedAt time.Time) error {
+	res, err := s.db.Exec(`INSERT INTO scans (started_at, version) VALUES (?, ?)`,
+		formatTime(startedAt), client.Version)
+	if err != nil {
+		return fmt.Errorf("failed to record scan: %w", err)
+	}
+
+	s.scanID, err = res.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("failed to record scan: %w", err)
+	}
+
+	return nil
+}
+
+// FinishScan records the end of the current scan and its outcome.
+func (s *Store) FinishScan(finishedAt time.Time, successCount, errorCount int) error {
+	_, err := s.db.Exec(`UPDATE scans SET finished_at = ?, success_count = ?, error_count = ? WHERE id = ?`,
+		formatTime(finishedAt), successCount, errorCount, s.scanID)
+	if err != nil {
+		return fmt.Errorf("failed to finish scan: %w", err)
+	}
+	return nil
+}
+
+// RecordResult stores a domain result in the current scan, updating the
+// first-seen and last-seen times of the domain and each of its URLs.
+func (s *Store) RecordResult(result *client.DomainResult) error {
+	if s.scanID == 0 {
+		return fmt.Errorf("no scan in progress")
+	}
+
+	domain := strings.ToLower(result.Domain)
+	seen := formatTime(result.Timestamp)
+	records := files.URLRecords(result, urlExtracts)
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`
+		INSERT INTO domains (domain, first_seen, last_seen) VALUES (?, ?, ?)
+		ON CONFLICT (domain) DO UPDATE SET last_seen = excluded.last_seen`,
+		domain, seen, seen); err != nil {
+		return fmt.Errorf("failed to record domain: %w", err)
+	}
+
+	if _, err := tx.Exec(`
+		INSERT OR REPLACE INTO domain_scans (scan_id, domain, scanned_at, response_code, url_count)
+		VALUES (?, ?, ?, ?, ?)`,
+		s.scanID, domain, seen, result.ResponseCode, len(records)); err != nil {
+		return fmt.Errorf("failed to record domain scan: %w", err)
+	}
+
+	for _, r := range records {
+		if _, err := tx.Exec(`
+			INSERT INTO urls (domain, url, source, positives, total, scan_date, first_seen, last_seen, first_scan_id, last_scan_id)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+			ON CONFLICT (domain, url) DO UPDATE SET
+				source = excluded.source,
+				positives = excluded.positives,
+				total = excluded.total,
+				scan_date = excluded.scan_date,
+				last_seen = excluded.last_seen,
+				last_scan_id = excluded.last_scan_id`,
+			domain, r.URL, r.Source, r.Positives, r.Total, r.ScanDate, seen, seen, s.scanID, s.scanID); err != nil {
+			return fmt.Errorf("failed to record URL: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit result: %w", err)
+	}
+
+	return nil
+}
+
+// NewURLs returns the URLs first seen in the latest scan of each domain, that
+// is, URLs that no earlier scan of the domain reported. An empty domain
+// covers every domain in the database.
+func (s *Store) NewURLs(domain string) ([]StoredURL, error) {
+	query := `
+		SELECT u.domain, u.url, u.source, u.positives, u.total, u.scan_date, u.first_seen, u.last_seen
+		FROM urls u
+		WHERE u.first_scan_id = (SELECT MAX(ds.scan_id) FROM domain_scans ds WHERE ds.domain = u.domain)`
+	var args []interface{}
+
+	if domain != "" {
+		query += ` AND u.domain = ?`
+		args = append(args, strings.ToLower(domain))
+	}
+	query += ` ORDER BY u.domain, u.url`
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query new URLs: %w", err)
+	}
+	defer rows.Close()
+
+	var urls []StoredURL
+	for rows.Next() {
+		var u StoredURL
+		var firstSeen, lastSeen string
+		if err := rows.Scan(&u.Domain, &u.URL, &u.Source, &u.Positives, &u.Total, &u.ScanDate, &firstSeen, &lastSeen); err != nil {
+			return nil, fmt.Errorf("failed to read URL: %w", err)
+		}
+		u.FirstSeen = parseTime(firstSeen)
+		u.LastSeen = parseTime(lastSeen)
+		urls = append(urls, u)
+	}
+
+	return urls, rows.Err()
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		t = time.Now()
+	}
+	return t.UTC().Format(time.RFC3339Nano)
+}
+
+func parseTime(value string) time.Time {
+	t, _ := time.Parse(time.RFC3339Nano, value)
+	return t
+}
@@ -0,0 +1,89 @@
+package store
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/pluckware/tyvt/internal/client"
+)
+
+func resultWithURLs(domain string, urls ...string) *client.DomainResult {
+	result := &client.DomainResult{Domain: domain, ResponseCode: 1, Timestamp: time.Now()}
+	for _, u := range urls {
+		result.UndetectedURLs = append(result.UndetectedURLs, client.UndetectedURL{URL: u, Total: 70})
+	}
+	return result
+}
+
+func recordScan(t *testing.T, s *Store, results ...*client.DomainResult) {
+	if err := s.BeginScan(time.Now()); err != nil {
+		t.Fatalf("BeginScan failed: %v", err)
+	}
+	for _, result := range results {
+		if err := s.RecordResult(result); err != nil {
+			t.Fatalf("RecordResult failed: %v", err)
+		}
+	}
+	if err := s.FinishScan(time.Now(), len(results), 0); err != nil {
+		t.Fatalf("FinishScan failed: %v", err)
+	}
+}
+
+func TestStore_NewURLsSincePreviousScan(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tyvt.db")
+
+	s, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+
+	recordScan(t, s,
+		resultWithURLs("example.com", "http://example.com/a", "http://example.com/b"),
+		resultWithURLs("example.org", "http://example.org/a"),
+	)
+	s.Close()
+
+	// History survives reopening the database
+	s, err = Open(path)
+	if err != nil {
+		t.Fatalf("Reopen failed: %v", err)
+	}
+	defer s.Close()
+
+	recordScan(t, s,
+		resultWithURLs("example.com", "http://example.com/a", "http://example.com/c"),
+	)
+
+	urls, err := s.NewURLs("example.com")
+	if err != nil {
+		t.Fatalf("NewURLs failed: %v", err)
+	}
+	if len(urls) != 1 || urls[0].URL != "http://example.com/c" {
+		t.Fatalf("Expected only the new URL, got %+v", urls)
+	}
+	if urls[0].Source != "undetected_urls" || urls[0].FirstSeen.IsZero() {
+		t.Errorf("Unexpected stored URL: %+v", urls[0])
+	}
+
+	// example.org was only scanned once, so all of its URLs are new
+	all, err := s.NewURLs("")
+	if err != nil {
+		t.Fatalf("NewURLs failed: %v", err)
+	}
+	if len(all) != 2 {
+		t.Errorf("Expected new URLs for both domains, got %+v", all)
+	}
+}
+
+func TestStore_RecordWithoutScan(t *testing.T) {
+	s, err := Open(filepath.Join(t.TempDir(), "tyvt.db"))
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer s.Close()
+
+	if err := s.RecordResult(resultWithURLs("example.com")); err == nil {
+		t.Error("Expected error when recording outside of a scan")
+	}
+}
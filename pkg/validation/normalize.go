@@ -0,0 +1,84 @@
+package validation
+
+import (
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// Normalization records how an input was turned into a domain name and
+// every transformation that was made, in the order they were applied.
+type Normalization struct {
+	Input   string
+	Domain  string
+	Changes []string
+}
+
+// NormalizeDomain extracts the domain name from input given as a URL
+// (https://example.com/path), a wildcard (*.example.com or .example.com),
+// an email address (admin@example.com) or a host with a port
+// (example.com:8443), as found in bug bounty scope lists. It returns the
+// domain and a description of each transformation made; a plain domain is
+// returned unchanged with no transformations. The result still has to be
+// validated with ValidateDomain.
+func NormalizeDomain(input string) (string, []string) {
+	domain := strings.TrimSpace(input)
+	var changes []string
+
+	if strings.Contains(domain, "://") {
+		if u, err := url.Parse(domain); err == nil && u.Host != "" {
+			domain = u.Host
+			changes = append(changes, "extracted host from URL")
+		}
+	} else if i := strings.IndexAny(domain, "/?#"); i >= 0 {
+		domain = domain[:i]
+		changes = append(changes, "removed path")
+	}
+
+	if i := strings.LastIndex(domain, "@"); i >= 0 {
+		domain = domain[i+1:]
+		changes = append(changes, "extracted domain from email address")
+	}
+
+	if i := strings.LastIndex(domain, ":"); i >= 0 {
+		if _, err := strconv.Atoi(domain[i+1:]); err == nil {
+			domain = domain[:i]
+			changes = append(changes, "removed port")
+		}
+	}
+
+	// A doubled dot is malformed rather than a wildcard, so it is kept for
+	// validation to reject
+	if strings.HasPrefix(domain, "*.") {
+		domain = domain[2:]
+		changes = append(changes, "removed wildcard")
+	} else if strings.HasPrefix(domain, ".") && !strings.HasPrefix(domain, "..") {
+		domain = domain[1:]
+		changes = append(changes, "removed wildcard")
+	}
+
+	if strings.HasSuffix(domain, ".") {
+		domain = strings.TrimSuffix(domain, ".")
+		changes = append(changes, "removed trailing dot")
+	}
+
+	return domain, changes
+}
+
+// NormalizeDomains normalizes every input with NormalizeDomain. It returns
+// the domains in input order and a Normalization for each input that was
+// changed.
+func NormalizeDomains(inputs []string) ([]string, []Normalization) {
+	domains := make([]string, 0, len(inputs))
+	var normalized []Normalization
+
+	for _, input := range inputs {
+		domain, changes := NormalizeDomain(input)
+		domains = append(domains, domain)
+		if len(changes) > 0 {
+			normalized = append(normalized, Normalization{Input: input, Domain: domain, Changes: changes})
+		}
+	}
+
+	return domains, normalized
+}
@@ -0,0 +1,55 @@
+package validation
+
+import (
+	"regexp"
+	"strings"
+)
+
+// apiKeyParamRegex matches the value of an apikey query parameter, as in
+// the v2 request URL that *url.Error includes in its message.
+var apiKeyParamRegex = regexp.MustCompile(`(?i)(apikey=)([^&\s"']+)`)
+
+// RedactedKey returns the form an API key takes in redacted text: its last
+// 4 characters behind asterisks.
+func RedactedKey(key string) string {
+	return "****" + MaskAPIKey(key)
+}
+
+// RedactKey replaces every occurrence of key in s with RedactedKey(key).
+func RedactKey(s, key string) string {
+	if key == "" {
+		return s
+	}
+	return strings.ReplaceAll(s, key, RedactedKey(key))
+}
+
+// RedactQuery masks the value of every apikey query parameter in s, so
+// that request URLs can be logged even when the key is not known.
+func RedactQuery(s string) string {
+	return apiKeyParamRegex.ReplaceAllStringFunc(s, func(param string) string {
+		parts := apiKeyParamRegex.FindStringSubmatch(param)
+		if strings.HasPrefix(parts[2], "****") {
+			return param
+		}
+		return parts[1] + RedactedKey(parts[2])
+	})
+}
+
+// Redactor masks a known set of API keys, and every apikey query
+// parameter, in text such as log lines.
+type Redactor struct {
+	keys []string
+}
+
+// NewRedactor returns a Redactor for keys.
+func NewRedactor(keys []string) *Redactor {
+	return &Redactor{keys: append([]string(nil), keys...)}
+}
+
+// Redact returns s with every known key and apikey parameter masked.
+func (r *Redactor) Redact(s string) string {
+	for _, key := range r.keys {
+		s = RedactKey(s, key)
+	}
+	return RedactQuery(s)
+}
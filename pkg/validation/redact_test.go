@@ -0,0 +1,38 @@
+package validation
+
+import (
+	"strings"
+	"testing"
+)
+
+const redactTestKey = "c9a9cfea8329cdf114760ed36fc8468dd1a1cb826d4adab9fee96bad9ec74add"
+
+func TestRedactQuery(t *testing.T) {
+	message := `Get "https://virustotal.com/vtapi/v2/domain/report?apikey=` + redactTestKey + `&domain=example.com": dial tcp: i/o timeout`
+
+	got := RedactQuery(message)
+	if strings.Contains(got, redactTestKey) {
+		t.Errorf("Key not redacted: %s", got)
+	}
+	if !strings.Contains(got, "apikey=****4add&domain=example.com") {
+		t.Errorf("Expected masked key with the rest of the URL intact, got %s", got)
+	}
+	if RedactQuery(got) != got {
+		t.Error("Redacting twice should not change the result")
+	}
+}
+
+func TestRedactor(t *testing.T) {
+	other := "3b3febd37b5f774837bdb9fa4d6cfc78d022ab2f35b1bba18b152d779a77cbb9"
+	r := NewRedactor([]string{redactTestKey, other})
+
+	message := "users/" + redactTestKey + "/overall_quotas failed; x-apikey: " + other
+	got := r.Redact(message)
+
+	if strings.Contains(got, redactTestKey) || strings.Contains(got, other) {
+		t.Errorf("Key not redacted: %s", got)
+	}
+	if !strings.Contains(got, "users/****4add/overall_quotas") || !strings.Contains(got, "x-apikey: ****cbb9") {
+		t.Errorf("Unexpected redaction: %s", got)
+	}
+}
@@ -112,13 +112,31 @@ func ValidateDomains(domains []string) (valid []string, errors []error) {
 	return valid, errors
 }
 
+// DedupeDomains returns domains without repeats, keeping the first
+// occurrence of each. Domain names are compared case-insensitively.
+func DedupeDomains(domains []string) []string {
+	seen := make(map[string]bool, len(domains))
+	unique := make([]string, 0, len(domains))
+
+	for _, domain := range domains {
+		name := strings.ToLower(domain)
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+		unique = append(unique, domain)
+	}
+
+	return unique
+}
+
 // ValidateAPIKeys validates a slice of API keys and returns all invalid ones
 // along with their error messages.
 func ValidateAPIKeys(keys []string) (valid []string, errors []error) {
 	for _, key := range keys {
 		if err := ValidateAPIKey(key); err != nil {
 			errors = append(errors, fmt.Errorf("API key (***%s): %w", 
-				maskAPIKey(key), err))
+				MaskAPIKey(key), err))
 		} else {
 			valid = append(valid, strings.TrimSpace(key))
 		}
@@ -126,9 +144,9 @@ func ValidateAPIKeys(keys []string) (valid []string, errors []error) {
 	return valid, errors
 }
 
-// maskAPIKey returns a masked version of an API key for safe logging
+// MaskAPIKey returns a masked version of an API key for safe logging.
 // Shows only last 4 characters
-func maskAPIKey(key string) string {
+func MaskAPIKey(key string) string {
 	if len(key) <= 4 {
 		return "****"
 	}
@@ -218,6 +218,16 @@ func TestValidateAPIKeys(t *testing.T) {
 	}
 }
 
+func TestDedupeDomains(t *testing.T) {
+	domains := []string{"example.com", "www.example.com", "Example.COM", "api.example.com", "www.example.com"}
+
+	got := DedupeDomains(domains)
+	want := []string{"example.com", "www.example.com", "api.example.com"}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("DedupeDomains() = %v, want %v", got, want)
+	}
+}
+
 func TestMaskAPIKey(t *testing.T) {
 	tests := []struct {
 		name string
@@ -232,9 +242,9 @@ func TestMaskAPIKey(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got := maskAPIKey(tt.key)
+			got := MaskAPIKey(tt.key)
 			if got != tt.want {
-				t.Errorf("maskAPIKey(%q) = %q, want %q", tt.key, got, tt.want)
+				t.Errorf("MaskAPIKey(%q) = %q, want %q", tt.key, got, tt.want)
 			}
 		})
 	}
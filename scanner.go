@@ -2,12 +2,21 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/pluckware/tyvt/internal/client"
+	"github.com/pluckware/tyvt/internal/limiter"
+	"github.com/pluckware/tyvt/internal/rotator"
 	"github.com/pluckware/tyvt/pkg/config"
+	"github.com/pluckware/tyvt/pkg/diff"
 	"github.com/pluckware/tyvt/pkg/files"
 	"github.com/pluckware/tyvt/pkg/logger"
+	"github.com/pluckware/tyvt/pkg/store"
+	"github.com/pluckware/tyvt/pkg/validation"
 )
 
 type Scanner struct {
@@ -15,6 +24,12 @@ type Scanner struct {
 	fileHandler *files.Handler
 	config      *config.Config
 	logger      *logger.Logger
+
+	journal   *files.Journal
+	completed map[string]*client.DomainResult
+	store     *store.Store
+	baseline  map[string]*client.DomainResult
+	extracts  client.ExtractSet
 }
 
 // ScanError represents a single scan error with context
@@ -24,7 +39,15 @@ type ScanError struct {
 }
 
 func (e ScanError) Error() string {
-	return fmt.Sprintf("domain %s: %v", e.Domain, e.Err)
+	return validation.RedactQuery(fmt.Sprintf("domain %s: %v", e.Domain, e.Err))
+}
+
+// scanTarget is a domain waiting to be scanned. Root is the input domain it
+// was discovered from and Depth how many subdomain hops separate the two.
+type scanTarget struct {
+	Domain string
+	Root   string
+	Depth  int
 }
 
 func NewScanner(client *client.VirusTotalClient, fileHandler *files.Handler, cfg *config.Config, logger *logger.Logger) *Scanner {
@@ -36,50 +59,248 @@ func NewScanner(client *client.VirusTotalClient, fileHandler *files.Handler, cfg
 	}
 }
 
-// Run processes all domains sequentially, respecting API rate limits.
+// SetCheckpoint records every finished lookup in journal and skips the
+// domains in completed, reusing their earlier results instead.
+func (s *Scanner) SetCheckpoint(journal *files.Journal, completed map[string]*client.DomainResult) {
+	s.journal = journal
+	s.completed = completed
+}
+
+// SetStore records the scan and every result in the SQLite history store.
+func (s *Scanner) SetStore(st *store.Store) {
+	s.store = st
+}
+
+// SetBaseline switches the output to diff mode: instead of each result,
+// only what was added or removed relative to baseline is written. baseline
+// is keyed by lower-cased domain, as returned by files.LoadBaseline. Only
+// the report parts in extracts are compared, as the baseline holds no
+// others.
+func (s *Scanner) SetBaseline(baseline map[string]*client.DomainResult, extracts client.ExtractSet) {
+	s.baseline = baseline
+	s.extracts = extracts
+}
+
+// resultWindowPerWorker bounds how many domains each worker may run ahead
+// of the oldest unfinished one, and with it the results held back to keep
+// the output in queue order.
+const resultWindowPerWorker = 4
+
+// scanJob is a lookup handed to a worker. Index is the target's position in
+// the scan queue.
+type scanJob struct {
+	Index  int
+	Target scanTarget
+}
+
+// scanOutcome is the finished lookup of a scanJob: the key that made the
+// last attempt, how many attempts it took and how long they took together.
+// Resumed outcomes were taken from the checkpoint instead of being looked up.
+type scanOutcome struct {
+	Index   int
+	Result  *client.DomainResult
+	Err     error
+	Key     string
+	Attempt int
+	Latency time.Duration
+	Resumed bool
+}
+
+// Run scans all domains with one worker per API key, each paced by the rate
+// limiter under its own key's quota. Results are collected in queue order
+// and streamed to the output as soon as every earlier domain is done, so
+// the output is the same as a sequential scan. Workers never run more than
+// resultWindowPerWorker domains each ahead of the collector, which bounds
+// the results held in memory.
+// In recursive mode, subdomains listed in each report are queued back into
+// the scan until the configured depth is reached. Every extra lookup goes
+// through the same client and rate limiter as the input domains.
 // Returns an error if more than 50% of domains fail to scan.
 func (s *Scanner) Run(ctx context.Context) error {
 	var results []*client.DomainResult
 	var errors []ScanError
-	totalDomains := len(s.config.Domains)
 
-	s.logger.Info("Processing %d domains sequentially to comply with API rate limits", totalDomains)
+	keys := s.client.Keys()
+	if len(keys) == 0 {
+		return fmt.Errorf("no API key available")
+	}
 
-	for i, domain := range s.config.Domains {
-		select {
-		case <-ctx.Done():
-			s.logger.Warn("Scan interrupted by context cancellation")
-			return ctx.Err()
-		default:
+	queue := make([]scanTarget, 0, len(s.config.Domains))
+	visited := make(map[string]bool)
+	for _, domain := range s.config.Domains {
+		name := strings.ToLower(domain)
+		if visited[name] {
+			continue
+		}
+		visited[name] = true
+		queue = append(queue, scanTarget{Domain: domain, Root: name})
+	}
+
+	// Results are streamed to the output as each domain completes. When
+	// resuming, the output already holds the earlier results, so it is
+	// appended to rather than truncated.
+	if err := s.fileHandler.Open(s.completed != nil); err != nil {
+		return err
+	}
+	defer func() {
+		if err := s.fileHandler.Close(); err != nil {
+			s.logger.Warn("Failed to write results to file: %v", err)
 		}
+	}()
 
-		s.logger.Info("Scanning domain %d/%d: %s", i+1, totalDomains, domain)
+	if s.store != nil {
+		if err := s.store.BeginScan(time.Now()); err != nil {
+			return err
+		}
+	}
 
-		result, err := s.client.QueryDomain(ctx, domain)
-		if err != nil {
-			s.logger.Error("Error querying domain %s: %v", domain, err)
-			errors = append(errors, ScanError{Domain: domain, Err: err})
+	jobs := make(chan scanJob)
+	outcomes := make(chan scanOutcome)
+	workerCtx, stopWorkers := context.WithCancel(ctx)
+	var wg sync.WaitGroup
+	for _, key := range keys {
+		wg.Add(1)
+		go func(key string) {
+			defer wg.Done()
+			s.work(workerCtx, key, jobs, outcomes)
+		}(key)
+	}
+	defer func() {
+		stopWorkers()
+		close(jobs)
+		wg.Wait()
+		s.logKeyHealth()
+	}()
+
+	s.logger.Info("Processing %d domains with %d workers (one per API key)", len(queue), len(keys))
+	if s.config.Recursive {
+		s.logger.Info("Recursive mode enabled (max depth %d)", s.config.MaxDepth)
+	}
+
+	window := resultWindowPerWorker * len(keys)
+	pending := make(map[int]scanOutcome)
+	dispatched := 0
+
+	for i := 0; i < len(queue); {
+		outcome, ready := pending[i]
+		if !ready {
+			var send chan<- scanJob
+			var job scanJob
+
+			if dispatched < len(queue) && dispatched-i < window {
+				target := queue[dispatched]
+				if result, resumed := s.completed[strings.ToLower(target.Domain)]; resumed {
+					s.logger.With("domain", target.Domain).Info("Skipping domain %d/%d: %s (completed in a previous run)", dispatched+1, len(queue), target.Domain)
+					pending[dispatched] = scanOutcome{Index: dispatched, Result: result, Resumed: true}
+					dispatched++
+					continue
+				}
+				send = jobs
+				job = scanJob{Index: dispatched, Target: target}
+			}
+
+			select {
+			case <-ctx.Done():
+				s.logger.Warn("Scan interrupted by context cancellation")
+				return ctx.Err()
+			case send <- job:
+				s.logger.With("domain", job.Target.Domain).Info("Scanning domain %d/%d: %s", job.Index+1, len(queue), job.Target.Domain)
+				dispatched++
+			case outcome := <-outcomes:
+				pending[outcome.Index] = outcome
+			}
 			continue
 		}
 
+		delete(pending, i)
+		target := queue[i]
+		result, err := outcome.Result, outcome.Err
+		i++
+
+		log := s.logger.With("domain", target.Domain)
+		if !outcome.Resumed {
+			log = log.With("attempt", outcome.Attempt, "latency", outcome.Latency)
+			if outcome.Key != "" {
+				log = log.With("key_id", limiter.KeyID(outcome.Key))
+			}
+			if status := errorStatus(err); status != 0 {
+				log = log.With("status", status)
+			}
+		}
+
+		if outcome.Resumed {
+			if s.baseline != nil && result != nil {
+				s.fileHandler.AddResumedDiff(s.compare(result))
+			} else {
+				s.fileHandler.AddResumedResult(result)
+			}
+		} else {
+			written := true
+			if err == nil && result != nil {
+				if err := s.write(result); err != nil {
+					log.Warn("Failed to write result for %s: %v", target.Domain, err)
+					written = false
+				}
+				if s.store != nil {
+					if err := s.store.RecordResult(result); err != nil {
+						log.Warn("Failed to store result for %s: %v", target.Domain, err)
+					}
+				}
+			}
+
+			// With every key exhausted or quarantined no other domain can
+			// succeed either, so the scan stops. The domain is not
+			// checkpointed, so that a resumed run retries it.
+			if noKeysLeft(err) {
+				log.Error("Stopping scan at %s: %v", target.Domain, err)
+				return err
+			}
+
+			// The checkpoint is written after the output so that an
+			// interruption in between repeats a line rather than losing it.
+			// A result that could not be written is not checkpointed, so
+			// that a resumed run scans the domain again.
+			if written {
+				s.checkpoint(target.Domain, result, err)
+			}
+			if err != nil {
+				log.Error("Error querying domain %s: %v", target.Domain, err)
+				errors = append(errors, ScanError{Domain: target.Domain, Err: err})
+				s.fileHandler.RecordError(target.Domain, err)
+				continue
+			}
+		}
+
 		if result != nil {
+			for _, fieldErr := range result.ParseErrors {
+				log.Warn("Could not parse field of %s response: %v", target.Domain, fieldErr)
+			}
+
 			results = append(results, result)
-			s.logger.Info("Successfully scanned domain: %s (%d undetected URLs)", result.Domain, len(result.UndetectedURLs))
+			log.Info("Successfully scanned domain: %s (%d undetected URLs, %d detected URLs, %d subdomains, %d resolutions)",
+				result.Domain, len(result.UndetectedURLs), len(result.DetectedURLs), len(result.Subdomains), len(result.Resolutions))
+
+			if s.config.Recursive {
+				discovered := s.expand(target, result, visited)
+				if len(discovered) > 0 {
+					log.Info("Queued %d new subdomains of %s (depth %d)", len(discovered), target.Domain, target.Depth+1)
+					queue = append(queue, discovered...)
+				}
+			}
 		}
 
 		// Log progress every 10 domains
-		if (i+1)%10 == 0 {
-			s.logger.Info("Progress: %d/%d domains scanned, %d successful, %d errors", 
-				i+1, totalDomains, len(results), len(errors))
+		if i%10 == 0 {
+			s.logger.Info("Progress: %d/%d domains scanned, %d successful, %d errors",
+				i, len(queue), len(results), len(errors))
 		}
 	}
 
-	// Write results to file if configured
-	if len(results) > 0 && s.fileHandler.HasOutputFile() {
-		if err := s.fileHandler.WriteResults(results); err != nil {
-			s.logger.Warn("Failed to write results to file: %v", err)
-		} else {
-			s.logger.Info("Results written to output file")
+	totalDomains := len(queue)
+
+	if s.store != nil {
+		if err := s.store.FinishScan(time.Now(), len(results), len(errors)); err != nil {
+			s.logger.Warn("Failed to record scan in database: %v", err)
 		}
 	}
 
@@ -98,4 +319,185 @@ func (s *Scanner) Run(ctx context.Context) error {
 	}
 
 	return nil
-}
\ No newline at end of file
+}
+
+// work looks up the jobs it receives with apiKey until jobs is closed or
+// ctx is cancelled. Pacing under the key's quota is left to the client's
+// rate limiter; once the key's quota is used up, the worker borrows
+// another key (see lookup).
+func (s *Scanner) work(ctx context.Context, apiKey string, jobs <-chan scanJob, outcomes chan<- scanOutcome) {
+	for job := range jobs {
+		outcome := s.lookup(ctx, apiKey, job.Target.Domain)
+		outcome.Index = job.Index
+
+		select {
+		case outcomes <- outcome:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// lookup queries domain with the worker's own key or, once that key has no
+// quota left or is quarantined, with the key the rotator selects instead.
+// A key can run out between being selected and being used, and a key the
+// API rejects is only quarantined after repeated failures, so the domain is
+// retried until every key could have been quarantined before giving up.
+// The returned outcome has no Index set.
+func (s *Scanner) lookup(ctx context.Context, home, domain string) scanOutcome {
+	attempts := len(s.client.Keys()) * rotator.QuarantineAfter
+	start := time.Now()
+
+	for attempt := 1; ; attempt++ {
+		apiKey, err := s.client.SelectKey(home)
+		if err != nil {
+			return scanOutcome{Err: err, Attempt: attempt, Latency: time.Since(start)}
+		}
+
+		result, err := s.client.QueryDomainWithKey(ctx, apiKey, domain)
+		log := s.logger.With("domain", domain, "key_id", limiter.KeyID(apiKey), "attempt", attempt)
+
+		var quotaErr *limiter.QuotaError
+		if errors.As(err, &quotaErr) && attempt < attempts {
+			log.Debug("Key quota used up while scanning %s, selecting another key: %v", domain, err)
+			continue
+		}
+
+		var statusErr *client.StatusError
+		if errors.As(err, &statusErr) && statusErr.AuthFailure() && attempt < attempts {
+			log.With("status", statusErr.Status).Debug("Key %s rejected while scanning %s, retrying: %v", validation.MaskAPIKey(apiKey), domain, err)
+			continue
+		}
+
+		return scanOutcome{Result: result, Err: err, Key: apiKey, Attempt: attempt, Latency: time.Since(start)}
+	}
+}
+
+// errorStatus returns the HTTP status of the API response err reports, or
+// 0 if err is not an API error.
+func errorStatus(err error) int {
+	var statusErr *client.StatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.Status
+	}
+	return 0
+}
+
+// noKeysLeft reports whether err means that no key can be used anymore,
+// because every key is out of quota or quarantined.
+func noKeysLeft(err error) bool {
+	var exhausted *rotator.ExhaustedError
+	return errors.As(err, &exhausted) || errors.Is(err, rotator.ErrAllQuarantined)
+}
+
+// logKeyHealth prints the health of every API key at the end of a run.
+func (s *Scanner) logKeyHealth() {
+	s.logger.Info("API key health:")
+	for _, health := range s.client.KeyHealth() {
+		state := string(health.State)
+		if health.Quarantined {
+			state += ", quarantined"
+		}
+		s.logger.With("key_id", limiter.KeyID(health.Key), "status", health.LastStatus).Info("  key ...%s: %s (%d requests, last status %d)",
+			validation.MaskAPIKey(health.Key), state, health.Requests, health.LastStatus)
+	}
+}
+
+// write streams a result to the output, or only its diff against the
+// baseline when one is set.
+func (s *Scanner) write(result *client.DomainResult) error {
+	if s.baseline == nil {
+		return s.fileHandler.AppendResult(result)
+	}
+
+	d := s.compare(result)
+	if !d.Empty() {
+		s.logger.With("domain", result.Domain).Info("Changes in %s since baseline: %d added, %d removed", result.Domain, diffSize(d.Added), diffSize(d.Removed))
+	}
+	return s.fileHandler.AppendDiff(d)
+}
+
+// compare returns the diff of result against its baseline entry, limited
+// to the selected extracts.
+func (s *Scanner) compare(result *client.DomainResult) *diff.DomainDiff {
+	baseline := s.baseline[strings.ToLower(result.Domain)]
+	return diff.Compare(s.extracts.Filter(baseline), s.extracts.Filter(result))
+}
+
+// diffSize counts the URLs, subdomains and resolutions in one side of a diff.
+func diffSize(r *client.DomainResult) int {
+	if r == nil {
+		return 0
+	}
+	return len(r.UndetectedURLs) + len(r.DetectedURLs) + len(r.Subdomains) + len(r.Resolutions)
+}
+
+// checkpoint records the outcome of a lookup in the journal, if one is set.
+// A lookup cancelled by shutdown is not recorded so that it is retried.
+// The output is flushed first, whatever the sync policy, so that the
+// journal never marks a domain as done whose result is still buffered.
+func (s *Scanner) checkpoint(domain string, result *client.DomainResult, err error) {
+	if s.journal == nil || (err != nil && errors.Is(err, context.Canceled)) {
+		return
+	}
+
+	if err := s.fileHandler.Flush(); err != nil {
+		s.logger.With("domain", domain).Warn("Failed to write checkpoint for %s: %v", domain, err)
+		return
+	}
+
+	entry := files.JournalEntry{Domain: domain, Result: result}
+	if err != nil {
+		entry.Result = nil
+		entry.Error = err.Error()
+	}
+
+	if err := s.journal.Record(entry); err != nil {
+		s.logger.With("domain", domain).Warn("Failed to write checkpoint for %s: %v", domain, err)
+	}
+}
+
+// expand returns the subdomains of result that should be scanned next:
+// within the depth limit, in scope, valid and not yet visited. Returned
+// names are marked as visited.
+func (s *Scanner) expand(target scanTarget, result *client.DomainResult, visited map[string]bool) []scanTarget {
+	if target.Depth >= s.config.MaxDepth {
+		return nil
+	}
+
+	var discovered []scanTarget
+	for _, subdomain := range result.Subdomains {
+		name := strings.ToLower(strings.TrimSpace(subdomain))
+		if visited[name] || !s.inScope(name, target.Root) {
+			continue
+		}
+
+		if err := validation.ValidateDomain(name); err != nil {
+			s.logger.With("domain", target.Domain).Debug("Skipping invalid subdomain %q: %v", subdomain, err)
+			continue
+		}
+
+		visited[name] = true
+		discovered = append(discovered, scanTarget{Domain: name, Root: target.Root, Depth: target.Depth + 1})
+	}
+
+	return discovered
+}
+
+// inScope reports whether name may be expanded. With an explicit scope the
+// name must fall under one of its domains; otherwise it must fall under the
+// input domain it was discovered from.
+func (s *Scanner) inScope(name, root string) bool {
+	scope := s.config.Scope
+	if len(scope) == 0 {
+		scope = []string{root}
+	}
+
+	for _, domain := range scope {
+		if name == domain || strings.HasSuffix(name, "."+domain) {
+			return true
+		}
+	}
+
+	return false
+}
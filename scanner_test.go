@@ -0,0 +1,474 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pluckware/tyvt/internal/client"
+	"github.com/pluckware/tyvt/internal/limiter"
+	"github.com/pluckware/tyvt/internal/rotator"
+	"github.com/pluckware/tyvt/pkg/config"
+	"github.com/pluckware/tyvt/pkg/files"
+	"github.com/pluckware/tyvt/pkg/logger"
+)
+
+// stubAPI serves v2 domain reports and records which domain was requested
+// with which key. report returns the body for a domain and may block to
+// delay the response. When retryAfter returns a value for a key, the
+// request is rate limited with that Retry-After instead.
+type stubAPI struct {
+	mu         sync.Mutex
+	requests   []stubRequest
+	report     func(domain string) string
+	retryAfter func(key string) string
+}
+
+type stubRequest struct {
+	Domain string
+	Key    string
+}
+
+func newStubAPI(t *testing.T, report func(domain string) string) (*stubAPI, string) {
+	api := &stubAPI{report: report}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		domain, key := r.URL.Query().Get("domain"), r.URL.Query().Get("apikey")
+
+		api.mu.Lock()
+		api.requests = append(api.requests, stubRequest{Domain: domain, Key: key})
+		retryAfter := ""
+		if api.retryAfter != nil {
+			retryAfter = api.retryAfter(key)
+		}
+		api.mu.Unlock()
+
+		if retryAfter != "" {
+			w.Header().Set("Retry-After", retryAfter)
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		w.Write([]byte(api.report(domain)))
+	}))
+	t.Cleanup(server.Close)
+	return api, server.URL + "/vtapi/v2/domain/report"
+}
+
+func (a *stubAPI) received() []stubRequest {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return append([]stubRequest(nil), a.requests...)
+}
+
+// urlReport is a v2 report listing a single undetected URL for domain.
+func urlReport(domain string) string {
+	return fmt.Sprintf(`{"response_code": 1, "undetected_urls": [["http://%s/", "", 0, 70, "2024-01-02 03:04:05"]]}`, domain)
+}
+
+func testKeys(n int) []string {
+	keys := make([]string, n)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("%064x", i+1)
+	}
+	return keys
+}
+
+func testDomains(n int) []string {
+	domains := make([]string, n)
+	for i := range domains {
+		domains[i] = fmt.Sprintf("d%d.example.com", i)
+	}
+	return domains
+}
+
+// newTestScanner returns a scanner with one worker per key, querying the
+// stub at endpoint and writing text output to the returned path.
+func newTestScanner(t *testing.T, endpoint string, keys, domains []string, rateLimiter *limiter.RateLimiter) (*Scanner, string) {
+	if rateLimiter == nil {
+		rateLimiter = limiter.New(time.Millisecond)
+	}
+
+	keyRotator := rotator.NewKeyRotator(keys, time.Second)
+	keyRotator.SetQuotaSource(rateLimiter)
+
+	vtClient := client.NewVirusTotalClient(keyRotator, rateLimiter, nil, false, client.APIv2)
+	vtClient.SetEndpoints(endpoint, "")
+	vtClient.SetRetryPolicy(client.RetryPolicy{MaxAttempts: 1})
+
+	output := filepath.Join(t.TempDir(), "out.txt")
+	cfg := &config.Config{Domains: domains, APIKeys: keys}
+
+	return NewScanner(vtClient, files.NewHandler(output, files.FormatText, nil), cfg, logger.Discard()), output
+}
+
+func readLines(t *testing.T, path string) []string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read output: %v", err)
+	}
+	if len(data) == 0 {
+		return nil
+	}
+	return strings.Split(strings.TrimSpace(string(data)), "\n")
+}
+
+func TestRun_OutputInQueueOrder(t *testing.T) {
+	domains := testDomains(9)
+
+	// Earlier domains take longer, so workers finish them out of order
+	delays := make(map[string]time.Duration)
+	for i, domain := range domains {
+		delays[domain] = time.Duration(len(domains)-i) * 5 * time.Millisecond
+	}
+	api, endpoint := newStubAPI(t, func(domain string) string {
+		time.Sleep(delays[domain])
+		return urlReport(domain)
+	})
+
+	s, output := newTestScanner(t, endpoint, testKeys(3), domains, nil)
+	if err := s.Run(context.Background()); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	lines := readLines(t, output)
+	if len(lines) != len(domains) {
+		t.Fatalf("Expected %d lines, got %v", len(domains), lines)
+	}
+	for i, domain := range domains {
+		if lines[i] != "http://"+domain+"/" {
+			t.Errorf("Line %d: expected %s, got %s", i, domain, lines[i])
+		}
+	}
+
+	keysUsed := make(map[string]bool)
+	for _, r := range api.received() {
+		keysUsed[r.Key] = true
+	}
+	if len(keysUsed) != 3 {
+		t.Errorf("Expected all 3 keys to be used, got %d", len(keysUsed))
+	}
+}
+
+func TestRun_BoundsWorkAheadOfCollector(t *testing.T) {
+	keys := testKeys(3)
+	window := resultWindowPerWorker * len(keys)
+	domains := testDomains(window * 3)
+
+	// The first domain blocks until released, so the collector cannot
+	// move past it while the other workers keep going
+	release := make(chan struct{})
+	api, endpoint := newStubAPI(t, func(domain string) string {
+		if domain == domains[0] {
+			<-release
+		}
+		return urlReport(domain)
+	})
+
+	s, output := newTestScanner(t, endpoint, keys, domains, nil)
+	done := make(chan error, 1)
+	go func() { done <- s.Run(context.Background()) }()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for len(api.received()) < window && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	time.Sleep(50 * time.Millisecond)
+
+	if got := len(api.received()); got != window {
+		t.Errorf("Expected work to stop %d domains ahead of the blocked one, got %d requests", window, got)
+	}
+
+	close(release)
+	if err := <-done; err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if lines := readLines(t, output); len(lines) != len(domains) || lines[0] != "http://"+domains[0]+"/" {
+		t.Errorf("Expected every domain in order once released, got %d lines", len(lines))
+	}
+}
+
+func TestRun_SwitchesKeyOnQuotaError(t *testing.T) {
+	keys := testKeys(2)
+	domains := testDomains(4)
+	api, endpoint := newStubAPI(t, urlReport)
+
+	// The first key has no quota left, so its worker borrows the second
+	rateLimiter := limiter.New(time.Millisecond)
+	rateLimiter.SetKeyLimits(keys[0], limiter.Limits{Daily: 0, Monthly: limiter.MonthlyLimit})
+
+	s, output := newTestScanner(t, endpoint, keys, domains, rateLimiter)
+	if err := s.Run(context.Background()); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	for _, r := range api.received() {
+		if r.Key != keys[1] {
+			t.Errorf("Request for %s used the exhausted key", r.Domain)
+		}
+	}
+	if lines := readLines(t, output); len(lines) != len(domains) {
+		t.Errorf("Expected all %d domains scanned, got %v", len(domains), lines)
+	}
+}
+
+func TestRun_StopsWhenAllKeysExhausted(t *testing.T) {
+	keys := testKeys(2)
+	domains := testDomains(5)
+	api, endpoint := newStubAPI(t, urlReport)
+
+	rateLimiter := limiter.New(time.Millisecond)
+	for _, key := range keys {
+		rateLimiter.SetKeyLimits(key, limiter.Limits{Daily: 1, Monthly: limiter.MonthlyLimit})
+	}
+
+	s, output := newTestScanner(t, endpoint, keys, domains, rateLimiter)
+	err := s.Run(context.Background())
+
+	var exhausted *rotator.ExhaustedError
+	if !errors.As(err, &exhausted) {
+		t.Fatalf("Expected ExhaustedError, got %v", err)
+	}
+	if got := len(api.received()); got != 2 {
+		t.Errorf("Expected one request per key, got %d", got)
+	}
+	if lines := readLines(t, output); len(lines) != 2 {
+		t.Errorf("Expected the 2 domains scanned before stopping, got %v", lines)
+	}
+}
+
+func TestRun_RecursiveQueuesNewSubdomainsOnce(t *testing.T) {
+	subdomains := map[string]string{
+		"example.com":        `["a.example.com", "A.example.com", "b.example.com", "other.org"]`,
+		"a.example.com":      `["example.com", "b.example.com", "deep.a.example.com"]`,
+		"b.example.com":      `[]`,
+		"deep.a.example.com": `[]`,
+	}
+	api, endpoint := newStubAPI(t, func(domain string) string {
+		return fmt.Sprintf(`{"response_code": 1, "subdomains": %s}`, subdomains[domain])
+	})
+
+	s, _ := newTestScanner(t, endpoint, testKeys(2), []string{"example.com"}, nil)
+	s.config.Recursive = true
+	s.config.MaxDepth = 1
+	if err := s.Run(context.Background()); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	counts := make(map[string]int)
+	for _, r := range api.received() {
+		counts[r.Domain]++
+	}
+
+	// other.org is out of scope and deep.a.example.com beyond the depth
+	expected := map[string]int{"example.com": 1, "a.example.com": 1, "b.example.com": 1}
+	if len(counts) != len(expected) {
+		t.Errorf("Expected %v, got %v", expected, counts)
+	}
+	for domain, n := range expected {
+		if counts[domain] != n {
+			t.Errorf("Expected %s to be scanned %d time(s), got %d", domain, n, counts[domain])
+		}
+	}
+}
+
+func TestRun_ResumeSkipsCompletedDomains(t *testing.T) {
+	domains := testDomains(4)
+	api, endpoint := newStubAPI(t, urlReport)
+
+	s, output := newTestScanner(t, endpoint, testKeys(2), domains, nil)
+	os.WriteFile(output, []byte("http://"+domains[1]+"/\n"), 0644)
+
+	completed := map[string]*client.DomainResult{domains[1]: {Domain: domains[1], ResponseCode: 1}}
+	s.SetCheckpoint(nil, completed)
+	if err := s.Run(context.Background()); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	for _, r := range api.received() {
+		if r.Domain == domains[1] {
+			t.Errorf("Completed domain %s was scanned again", r.Domain)
+		}
+	}
+	if got := len(api.received()); got != 3 {
+		t.Errorf("Expected 3 lookups, got %d", got)
+	}
+
+	// Earlier output is kept and the remaining domains are appended
+	if lines := readLines(t, output); len(lines) != 4 || lines[0] != "http://"+domains[1]+"/" {
+		t.Errorf("Expected earlier output followed by 3 results, got %v", lines)
+	}
+}
+
+func TestRun_DiffComparesSelectedExtracts(t *testing.T) {
+	_, endpoint := newStubAPI(t, func(domain string) string {
+		return fmt.Sprintf(`{"response_code": 1,
+			"undetected_urls": [["http://%s/", "", 0, 70, "2024-01-02 03:04:05"]],
+			"subdomains": ["www.%s"],
+			"resolutions": [{"ip_address": "192.0.2.1", "last_resolved": "2024-01-02 03:04:05"}]}`, domain, domain)
+	})
+
+	// A per-URL baseline holds only the URLs, all of them unchanged
+	baseline := map[string]*client.DomainResult{
+		"example.com": {Domain: "example.com", ResponseCode: 1, UndetectedURLs: []client.UndetectedURL{{URL: "http://example.com/"}}},
+	}
+
+	s, output := newTestScanner(t, endpoint, testKeys(1), []string{"example.com"}, nil)
+	s.fileHandler = files.NewHandler(output, files.FormatJSONL, nil)
+	s.SetBaseline(baseline, client.DefaultExtracts())
+	if err := s.Run(context.Background()); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	if lines := readLines(t, output); len(lines) != 0 {
+		t.Errorf("Expected no changes, got %v", lines)
+	}
+}
+
+func TestRun_LongRetryAfterDoesNotStallScan(t *testing.T) {
+	keys := testKeys(2)
+	domains := testDomains(20)
+	api, endpoint := newStubAPI(t, urlReport)
+
+	// The first request with the first key is told to come back in an hour
+	limited := false
+	api.retryAfter = func(key string) string {
+		if key == keys[0] && !limited {
+			limited = true
+			return "3600"
+		}
+		return ""
+	}
+
+	s, output := newTestScanner(t, endpoint, keys, domains, nil)
+	s.client.SetRetryPolicy(client.RetryPolicy{MaxAttempts: 1, MaxDelay: 100 * time.Millisecond})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := s.Run(ctx); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	if lines := readLines(t, output); len(lines) != len(domains)-1 {
+		t.Errorf("Expected every domain but the rate limited one, got %d lines", len(lines))
+	}
+}
+
+func TestRun_SwitchesAwayFromThrottledKey(t *testing.T) {
+	keys := testKeys(2)
+	domains := testDomains(20)
+	api, endpoint := newStubAPI(t, urlReport)
+	api.retryAfter = func(key string) string {
+		if key == keys[0] {
+			return "3600"
+		}
+		return ""
+	}
+
+	// The retry policy allows the full hour, so the first key stays
+	// throttled and its worker has to borrow the second
+	s, output := newTestScanner(t, endpoint, keys, domains, nil)
+	s.client.SetRetryPolicy(client.RetryPolicy{MaxAttempts: 1, MaxDelay: 2 * time.Hour})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := s.Run(ctx); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	throttled := 0
+	for _, r := range api.received() {
+		if r.Key == keys[0] {
+			throttled++
+		}
+	}
+	if throttled != 1 {
+		t.Errorf("Expected a single request with the throttled key, got %d", throttled)
+	}
+	if lines := readLines(t, output); len(lines) != len(domains)-1 {
+		t.Errorf("Expected every domain but the rate limited one, got %d lines", len(lines))
+	}
+}
+
+func TestRun_CheckpointFollowsBufferedOutput(t *testing.T) {
+	domains := testDomains(3)
+
+	// The last domain blocks, so the run is still going while the
+	// checkpoint of the first is inspected
+	release := make(chan struct{})
+	_, endpoint := newStubAPI(t, func(domain string) string {
+		if domain == domains[2] {
+			<-release
+		}
+		return urlReport(domain)
+	})
+
+	s, output := newTestScanner(t, endpoint, testKeys(1), domains, nil)
+	s.fileHandler.SetSyncPolicy(files.SyncNone)
+
+	path := filepath.Join(t.TempDir(), "scan.checkpoint")
+	journal, _, err := files.OpenJournal(path, false)
+	if err != nil {
+		t.Fatalf("OpenJournal failed: %v", err)
+	}
+	defer journal.Close()
+	s.SetCheckpoint(journal, nil)
+
+	done := make(chan error, 1)
+	go func() { done <- s.Run(context.Background()) }()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if data, _ := os.ReadFile(path); strings.Contains(string(data), domains[1]) {
+			break
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	// Even without syncing, every checkpointed domain is in the output
+	if lines := readLines(t, output); len(lines) != 2 {
+		t.Errorf("Expected the 2 checkpointed domains in the output, got %v", lines)
+	}
+
+	close(release)
+	if err := <-done; err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+}
+
+func TestRun_FailedWriteIsNotCheckpointed(t *testing.T) {
+	if _, err := os.Stat("/dev/full"); err != nil {
+		t.Skip("/dev/full is not available")
+	}
+
+	domains := testDomains(2)
+	_, endpoint := newStubAPI(t, urlReport)
+
+	// Every write to /dev/full fails with no space left on device
+	s, _ := newTestScanner(t, endpoint, testKeys(1), domains, nil)
+	s.fileHandler = files.NewHandler("/dev/full", files.FormatText, nil)
+
+	path := filepath.Join(t.TempDir(), "scan.checkpoint")
+	journal, _, err := files.OpenJournal(path, false)
+	if err != nil {
+		t.Fatalf("OpenJournal failed: %v", err)
+	}
+	s.SetCheckpoint(journal, nil)
+
+	s.Run(context.Background())
+	journal.Close()
+
+	_, entries, err := files.OpenJournal(path, true)
+	if err != nil {
+		t.Fatalf("Failed to read checkpoint: %v", err)
+	}
+	if completed := files.CompletedResults(entries); len(completed) != 0 {
+		t.Errorf("Expected no domain to be checkpointed, got %d", len(completed))
+	}
+}